@@ -1,9 +1,14 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"test-task/internal/app"
 	"test-task/internal/config"
@@ -11,32 +16,62 @@ import (
 	"github.com/gorilla/mux"
 )
 
-func main() {
-
-	/* 	//ctx, cancel := context.WithCancel(context.Background())
-	   	defer cancel()*/
+const shutdownTimeout = 10 * time.Second
 
-	/* 	sigchan := make(chan os.Signal, 1)
-	   	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
-	*/
+func main() {
 	config, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to get config: %v", err)
 	}
-	newApp, err := app.NewApp(fmt.Sprintf("postgres://%s:%s@db:5432/%s",
-		config.DBuser,
-		config.DBpassword,
-		config.DBname,
-	))
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(config, os.Args[2:]))
+	}
+
+	newApp, err := app.NewApp(config)
 	if err != nil {
 		log.Fatalf("Failed to initialize")
 	}
 	defer newApp.Close()
 
 	r := mux.NewRouter()
+	r.Use(app.RequestID)
+	r.NotFoundHandler = app.RequestID(http.HandlerFunc(app.NotFound))
+	r.MethodNotAllowedHandler = app.RequestID(http.HandlerFunc(app.MethodNotAllowed))
 
 	r.HandleFunc("/", newApp.HomeHandler)
 	r.HandleFunc("/order/{order_uid}", newApp.GetOrderById).Methods("GET")
+	r.HandleFunc("/orders", newApp.ListOrders).Methods("GET")
+	r.HandleFunc("/orders/search", newApp.SearchOrders).Methods("GET")
+	r.HandleFunc("/orders/by-track/{track_number}", newApp.GetOrdersByTrack).Methods("GET")
+	r.HandleFunc("/customers/{customer_id}/orders", newApp.CustomerOrders).Methods("GET")
 	r.HandleFunc("/add", newApp.CreateOrders).Methods("GET")
-	http.ListenAndServe(":8080", r)
+	r.HandleFunc("/dlq", newApp.ListDLQ).Methods("GET")
+	r.HandleFunc("/dlq/{partition}/{offset}/redrive", newApp.RedriveDLQ).Methods("POST")
+
+	r.HandleFunc("/admin/cache/stats", newApp.CacheStats).Methods("GET")
+	r.HandleFunc("/admin/cache/orders/{order_uid}", newApp.CachedOrder).Methods("GET")
+	r.HandleFunc("/admin/cache/orders/{order_uid}", newApp.EvictCachedOrder).Methods("DELETE")
+	r.HandleFunc("/admin/cache/flush", newApp.FlushCache).Methods("POST")
+	r.HandleFunc("/admin/cache/rewarm", newApp.RewarmCache).Methods("POST")
+
+	server := &http.Server{Addr: ":8080", Handler: r}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server failed: %v", err)
+			sigchan <- syscall.SIGTERM
+		}
+	}()
+
+	sig := <-sigchan
+	log.Printf("Received %v, shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("HTTP server shutdown error: %v", err)
+	}
 }
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// cacheEnabled answers 404 when the store keeps no cache.
+func (a *App) cacheEnabled(w http.ResponseWriter, r *http.Request) bool {
+	if a.cacheAdmin == nil {
+		notFound(w, r, "cache is disabled")
+		return false
+	}
+	return true
+}
+
+func (a *App) CacheStats(w http.ResponseWriter, r *http.Request) {
+	if !a.cacheEnabled(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, a.cacheAdmin.CacheStats())
+}
+
+func (a *App) CachedOrder(w http.ResponseWriter, r *http.Request) {
+	if !a.cacheEnabled(w, r) {
+		return
+	}
+	orderUid := mux.Vars(r)["order_uid"]
+	writeJSON(w, http.StatusOK, map[string]any{
+		"order_uid": orderUid,
+		"cached":    a.cacheAdmin.IsCached(orderUid),
+	})
+}
+
+func (a *App) EvictCachedOrder(w http.ResponseWriter, r *http.Request) {
+	if !a.cacheEnabled(w, r) {
+		return
+	}
+	orderUid := mux.Vars(r)["order_uid"]
+	evicted := a.cacheAdmin.EvictFromCache(orderUid)
+	log.Printf("Evict %v from cache: %v", orderUid, evicted)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"order_uid": orderUid,
+		"evicted":   evicted,
+	})
+}
+
+func (a *App) FlushCache(w http.ResponseWriter, r *http.Request) {
+	if !a.cacheEnabled(w, r) {
+		return
+	}
+	a.cacheAdmin.FlushCache()
+	writeJSON(w, http.StatusOK, a.cacheAdmin.CacheStats())
+}
+
+func (a *App) RewarmCache(w http.ResponseWriter, r *http.Request) {
+	if !a.cacheEnabled(w, r) {
+		return
+	}
+	if err := a.cacheAdmin.RewarmCache(); err != nil {
+		failed(w, r, "failed to rewarm cache", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.cacheAdmin.CacheStats())
+}
@@ -10,9 +10,14 @@ import (
 	"os"
 	"time"
 
-
-	"test-task/pkg/models"
+	"test-task/internal/cache"
+	"test-task/internal/config"
+	"test-task/internal/dlq"
+	"test-task/internal/migrations"
+	"test-task/internal/retry"
 	"test-task/internal/storage"
+	"test-task/internal/validation"
+	"test-task/pkg/models"
 
 	"github.com/IBM/sarama"
 	"github.com/brianvoe/gofakeit/v7"
@@ -20,106 +25,124 @@ import (
 )
 
 type App struct {
-	repository storage.Repository
+	store storage.OrderStore
+	// cacheAdmin is nil when the store keeps no cache.
+	cacheAdmin storage.CacheAdmin
 	consumer   sarama.ConsumerGroup
-	stopChan   chan struct{}
+	dlq        *dlq.Queue
+	topic      string
+
+	// batchSize > 1 switches the consumer to batch mode, see consumeBatches.
+	batchSize   int
+	batchWindow time.Duration
+	retryPolicy retry.Policy
+
+	stopChan chan struct{}
+	doneChan chan struct{}
 }
 
-func NewApp(connStr string) (*App, error) {
-	app := &App{}
-	err := app.repository.InitRepository(connStr)
+func NewApp(cfg *config.Config) (*App, error) {
+	retryPolicy := retry.DefaultPolicy()
+	retryPolicy.MaxAttempts = cfg.RetryMaxAttempts
+	retryPolicy.InitialInterval = cfg.RetryInitialInterval
+	retryPolicy.MaxInterval = cfg.RetryMaxInterval
+
+	app := &App{
+		topic:       cfg.KafkaTopic,
+		batchSize:   cfg.KafkaBatchSize,
+		batchWindow: cfg.KafkaBatchWindow,
+		retryPolicy: retryPolicy,
+		stopChan:    make(chan struct{}),
+		doneChan:    make(chan struct{}),
+	}
+	if cfg.MigrateOnStart {
+		if err := migrateUp(cfg.ConnString()); err != nil {
+			log.Printf("Unable to migrate database: %v", err)
+			return nil, err
+		}
+	}
+
+	repository := &storage.Repository{}
+	err := repository.InitRepository(cfg.ConnString(), storage.CacheOptions{
+		Capacity:    cfg.CacheCapacity,
+		MaxBytes:    cfg.CacheMaxBytes,
+		TTL:         cfg.CacheTTL,
+		Policy:      cache.PolicyName(cfg.CachePolicy),
+		WarmupSize:  cfg.CacheWarmupSize,
+		WarmupOrder: storage.WarmupOrder(cfg.CacheWarmupOrder),
+
+		NegativeTTL:      cfg.NegativeCacheTTL,
+		NegativeCapacity: cfg.NegativeCacheCapacity,
+
+		TrackTTL:      cfg.TrackCacheTTL,
+		TrackCapacity: cfg.TrackCacheCapacity,
+
+		SnapshotPath:   cfg.CacheSnapshotPath,
+		SnapshotMaxAge: cfg.CacheSnapshotMaxAge,
+	})
 	if err != nil {
 		log.Printf("Unable to connect to database: %v", err)
 		return nil, err
 	}
+	app.store = repository
+	app.cacheAdmin = repository
 
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{
 		sarama.NewBalanceStrategyRoundRobin(),
 	}
-	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	// A new group starts from the beginning of the topic so orders produced
+	// before the first deployment are not skipped.
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
 	config.Consumer.Offsets.AutoCommit.Enable = false
 	config.Consumer.Return.Errors = true
 
 	consumerGroup, err := sarama.NewConsumerGroup(
-		[]string{"kafka:9092"},
-		"orders-consumer-group",
+		cfg.KafkaBrokers,
+		cfg.KafkaGroup,
 		config,
 	)
 	if err != nil {
+		app.store.Close()
 		return nil, err
 	}
 
 	app.consumer = consumerGroup
 
+	if cfg.KafkaDLQTopic != "" {
+		app.dlq, err = dlq.NewQueue(cfg.KafkaBrokers, cfg.KafkaDLQTopic, cfg.KafkaTopic)
+		if err != nil {
+			consumerGroup.Close()
+			app.store.Close()
+			return nil, err
+		}
+	}
+
 	go app.runConsumer()
 
 	return app, nil
 }
 
-func (a *App) runConsumer() {
-    ctx, cancel := context.WithCancel(context.Background())
-    defer cancel()
-
-    go func() {
-        <-a.stopChan
-        cancel()
-    }()
-
-    // Самый простой потребитель без группы (не использует ConsumerGroup)
-    consumer, err := sarama.NewConsumer([]string{"kafka:9092"}, nil)
-    if err != nil {
-        log.Printf("failed to create consumer: %v", err)
-        return
-    }
-    defer consumer.Close()
-
-    partitionConsumer, err := consumer.ConsumePartition("orders", 0, sarama.OffsetNewest)
-    if err != nil {
-        log.Printf("failed to consume partition: %v", err)
-        return
-    }
-    defer partitionConsumer.Close()
-
-    log.Println("simple kafka consumer started (partition 0)")
-
-    for {
-        select {
-        case msg, ok := <-partitionConsumer.Messages():
-            if !ok {
-                log.Println("messages channel closed")
-                return
-            }
-
-            var order models.Order
-            if err := json.Unmarshal(msg.Value, &order); err != nil {
-                log.Printf("unmarshal error: %v", err)
-                continue
-            }
-
-            if err := a.repository.InsertToDB(&order); err != nil {
-                log.Printf("store error: %v", err)
-                // можно добавить retry или логирование
-            }
-
-            log.Printf("processed order %s from offset %d", order.OrderUID, msg.Offset)
-
-        case <-ctx.Done():
-            log.Println("consumer stopped by context")
-            return
-
-        case err := <-partitionConsumer.Errors():
-            log.Printf("consumer error: %v", err)
-        }
-    }
+func migrateUp(connStr string) error {
+	ctx := context.Background()
+	migrator, err := migrations.Connect(ctx, connStr)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close(ctx)
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("Applied %d migrations", applied)
+	return nil
 }
 
 func (a *App) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	html, err := os.ReadFile("frontend/index.html")
 	if err != nil {
-		log.Printf("Error reading index.html: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "internal error")
+		failed(w, r, "failed to read index.html", err)
 		return
 	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -127,25 +150,21 @@ func (a *App) HomeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(html)
 }
 
+// GetOrderById serves GET /order/{order_uid}.
 func (a *App) GetOrderById(w http.ResponseWriter, r *http.Request) {
 	orderUid := mux.Vars(r)["order_uid"]
 	log.Printf("Searching : %v", orderUid)
 
-	order, exist, err := a.repository.FindOrderById(orderUid)
-
-	if !exist {
-		fmt.Fprintf(w, "Order %v does not exist\n", orderUid)
-		return
-	} else if err != nil {
-		log.Printf("Finding order by id is failed: %v", err)
+	order, exist, err := a.store.FindOrderById(orderUid)
+	if err != nil {
+		failed(w, r, fmt.Sprintf("failed to find order %s", orderUid), err)
 		return
 	}
-
-	json_data, err := json.MarshalIndent(order, "", "\t")
-	if err != nil {
-		log.Printf("Failed to create json: %v", err)
+	if !exist {
+		notFound(w, r, fmt.Sprintf("order %s does not exist", orderUid))
+		return
 	}
-	fmt.Fprintf(w, "%s\n", json_data)
+	writeJSON(w, http.StatusOK, order)
 }
 
 /* func (a *App) HandleGetOrderByID(uid string) (interface{}, error) {
@@ -211,33 +230,51 @@ func (a *App) HandleCreateOrders(data string) (interface{}, error) {
 	}
 } */ 
 
+// CreateOrders serves GET /add: it generates and stores a couple of random
+// orders and returns the stored ones. It fails only if none is stored.
 func (a *App) CreateOrders(w http.ResponseWriter, r *http.Request) {
 
 	amount := 2
 	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 
-	var orders []models.Order
+	orders := []models.Order{}
+	var lastErr error
 	for i := 0; i < amount; i++ {
 		order, err := createRandomOrder(rng)
 		if err != nil {
 			log.Printf("Failed to generate order #%d: %v", i+1, err)
+			lastErr = err
 			continue
 		}
 
-		if err := a.repository.InsertToDB(&order); err != nil {
+		if err := validation.ValidateOrder(&order); err != nil {
+			log.Printf("Generated order #%d is invalid: %v", i+1, err)
+			lastErr = err
+			continue
+		}
+
+		if _, err := a.store.InsertToDB(&order); err != nil {
 			log.Printf("Failed to insert order #%d: %v", i+1, err)
+			lastErr = err
 			continue
 		}
 
 		orders = append(orders, order)
 	}
 
-	json_data, err := json.MarshalIndent(orders, "", "  ")
-	if err != nil {
-		log.Printf("Error making json: %v", err)
+	if len(orders) == 0 && lastErr != nil {
+		failed(w, r, "failed to store generated orders", lastErr)
+		return
 	}
+	writeJSON(w, http.StatusOK, orders)
+}
 
-	fmt.Fprintf(w, "%s\n", json_data)
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error while creating response: %v", err)
+	}
 }
 
 func createRandomOrder(rng *rand.Rand) (models.Order, error) {
@@ -257,7 +294,7 @@ func createRandomOrder(rng *rand.Rand) (models.Order, error) {
 
 	itemCount := rng.Intn(10) + 1
 	items := make([]models.Item, 0, itemCount)
-	var goodsTotal float64
+	var goodsTotal models.Money
 
 	for i := 0; i < itemCount; i++ {
 		var item models.Item
@@ -269,7 +306,7 @@ func createRandomOrder(rng *rand.Rand) (models.Order, error) {
 
 		item.Sale = rng.Intn(51)
 
-		item.TotalPrice = float64(item.Price*quantity) * (1 - float64(item.Sale)/100.0)
+		item.TotalPrice = item.Price.Times(quantity).Percent(100 - item.Sale)
 
 		goodsTotal += item.TotalPrice
 		items = append(items, item)
@@ -286,13 +323,17 @@ func createRandomOrder(rng *rand.Rand) (models.Order, error) {
 }
 
 func (a *App) Close() {
-	a.repository.Close()
 	log.Println("Stopping consumer...")
 	close(a.stopChan)
-
-	time.Sleep(3 * time.Second)
+	<-a.doneChan
 
 	if err := a.consumer.Close(); err != nil {
 		log.Printf("error closing consumer: %v", err)
 	}
+	if a.dlq != nil {
+		if err := a.dlq.Close(); err != nil {
+			log.Printf("error closing dlq: %v", err)
+		}
+	}
+	a.store.Close()
 }
@@ -0,0 +1,148 @@
+package app
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/IBM/sarama"
+)
+
+// consumeBatches collects up to batchSize messages of the claim and stores
+// them with one InsertBatch. A batch that is not full is flushed batchWindow
+// after its first message. The last offset of a batch is committed only
+// after the whole batch is processed.
+func (h *orderHandler) consumeBatches(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	batch := make([]*sarama.ConsumerMessage, 0, h.app.batchSize)
+	timer := time.NewTimer(h.app.batchWindow)
+	timer.Stop()
+	defer timer.Stop()
+	// window is nil while the batch is empty.
+	var window <-chan time.Time
+
+	flush := func() error {
+		timer.Stop()
+		window = nil
+		if len(batch) == 0 {
+			return nil
+		}
+		if done, err := h.app.processBatch(session.Context(), batch); err != nil {
+			// The messages already processed are committed, so the next
+			// session does not forward them to the dead-letter topic again.
+			if done > 0 {
+				session.MarkMessage(batch[done-1], "")
+				session.Commit()
+			}
+			log.Printf("partition %d offsets %d-%d are not processed: %v",
+				claim.Partition(), batch[done].Offset, batch[len(batch)-1].Offset, err)
+			return endSession(session, err)
+		}
+		session.MarkMessage(batch[len(batch)-1], "")
+		session.Commit()
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				log.Printf("messages channel of partition %d closed", claim.Partition())
+				return flush()
+			}
+			batch = append(batch, msg)
+			if len(batch) == 1 {
+				timer.Reset(h.app.batchWindow)
+				window = timer.C
+			}
+			if len(batch) >= h.app.batchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+
+		case <-window:
+			if err := flush(); err != nil {
+				return err
+			}
+
+		case <-session.Context().Done():
+			// Unflushed messages are not marked and are read again by the
+			// next session.
+			return nil
+		}
+	}
+}
+
+// processBatch is processMessage for a batch of messages of one partition.
+// On error it also returns how many leading messages of the batch were
+// processed and may be committed.
+//
+// Nothing is forwarded to the dead-letter topic before the valid orders are
+// stored, so a transient failure replays the batch without duplicating
+// dead-lettered messages.
+func (a *App) processBatch(ctx context.Context, batch []*sarama.ConsumerMessage) (int, error) {
+	orders := make([]*models.Order, len(batch))
+	reasons := make([]error, len(batch))
+	var valid []*models.Order
+	var messages []*sarama.ConsumerMessage
+	for i, msg := range batch {
+		orders[i], reasons[i] = decodeOrder(msg)
+		if reasons[i] == nil {
+			valid = append(valid, orders[i])
+			messages = append(messages, msg)
+		}
+	}
+
+	if len(valid) > 0 {
+		var outcomes []storage.InsertOutcome
+		err := a.retryPaused(ctx, messages[0], "store batch", func() error {
+			var err error
+			outcomes, err = a.store.InsertBatch(valid)
+			return err
+		})
+		if storage.IsTransient(err) {
+			return 0, err
+		}
+		if err != nil {
+			// Any one order can make the whole batch fail. Storing them one
+			// by one sends only the offending orders to the dead-letter
+			// topic.
+			log.Printf("storing batch of %d orders failed, storing one by one: %v", len(valid), err)
+			return a.processOneByOne(ctx, batch, orders, reasons)
+		}
+		for i, msg := range messages {
+			log.Printf("processed order %s from partition %d offset %d: %v",
+				valid[i].OrderUID, msg.Partition, msg.Offset, outcomes[i])
+		}
+	}
+
+	for i, msg := range batch {
+		if reasons[i] == nil {
+			continue
+		}
+		if err := a.rejectMessage(msg, reasons[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
+
+// processOneByOne processes the decoded batch message by message, in order.
+func (a *App) processOneByOne(ctx context.Context, batch []*sarama.ConsumerMessage, orders []*models.Order, reasons []error) (int, error) {
+	for i, msg := range batch {
+		var err error
+		if reasons[i] != nil {
+			err = a.rejectMessage(msg, reasons[i])
+		} else {
+			err = a.storeMessage(ctx, msg, orders[i])
+		}
+		if err != nil {
+			return i, err
+		}
+	}
+	return len(batch), nil
+}
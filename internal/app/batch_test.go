@@ -0,0 +1,218 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task/internal/dlq"
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+// rejectingStore is a MemoryStore that fails permanently on the order with
+// uid, and on any batch containing it.
+type rejectingStore struct {
+	*storage.MemoryStore
+	uid string
+}
+
+func (store rejectingStore) InsertToDB(order *models.Order) (storage.InsertOutcome, error) {
+	if order.OrderUID == store.uid {
+		return 0, errPermanent
+	}
+	return store.MemoryStore.InsertToDB(order)
+}
+
+func (store rejectingStore) InsertBatch(orders []*models.Order) ([]storage.InsertOutcome, error) {
+	for _, order := range orders {
+		if order.OrderUID == store.uid {
+			return nil, errPermanent
+		}
+	}
+	return store.MemoryStore.InsertBatch(orders)
+}
+
+func batchingApp(store storage.OrderStore, size int, window time.Duration) *App {
+	return &App{store: store, retryPolicy: noRetries, batchSize: size, batchWindow: window}
+}
+
+func orderNumbered(n int) models.Order {
+	order := testOrder()
+	order.OrderUID = fmt.Sprintf("order-%d", n)
+	return order
+}
+
+// startClaim runs ConsumeClaim until the session's context is done. The
+// claim is unbuffered, so a send returns once ConsumeClaim has the message.
+func startClaim(a *App, session *fakeSession) (chan<- *sarama.ConsumerMessage, <-chan error) {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage)}
+	done := make(chan error, 1)
+	go func() { done <- (&orderHandler{app: a}).ConsumeClaim(session, claim) }()
+	return claim.messages, done
+}
+
+// waitMarked waits until the session has as many marks as wanted.
+func waitMarked(t *testing.T, session *fakeSession, wanted int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if marked, _ := session.state(); len(marked) >= wanted {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Session has no %d marked offsets in time", wanted)
+}
+
+func TestConsumeBatches_FullBatch(t *testing.T) {
+	store := storage.NewMemoryStore()
+	a := batchingApp(store, 3, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx)
+
+	messages, done := startClaim(a, session)
+	for i := range 3 {
+		messages <- orderMessage(t, int64(i), orderNumbered(i))
+	}
+	waitMarked(t, session, 1)
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ConsumeClaim() error = %v", err)
+	}
+
+	assertMarked(t, session, []int64{2}, 1)
+	for i := range 3 {
+		if _, exist, _ := store.FindOrderById(orderNumbered(i).OrderUID); !exist {
+			t.Errorf("Order %d of the batch is not stored", i)
+		}
+	}
+}
+
+func TestConsumeBatches_WindowFlush(t *testing.T) {
+	store := storage.NewMemoryStore()
+	a := batchingApp(store, 10, 10*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx)
+
+	messages, done := startClaim(a, session)
+	messages <- orderMessage(t, 0, orderNumbered(0))
+	waitMarked(t, session, 1)
+	cancel()
+	<-done
+
+	assertMarked(t, session, []int64{0}, 1)
+	if _, exist, _ := store.FindOrderById(orderNumbered(0).OrderUID); !exist {
+		t.Error("Order flushed by the window is not stored")
+	}
+}
+
+func TestConsumeBatches_UnflushedBatchIsNotMarked(t *testing.T) {
+	store := storage.NewMemoryStore()
+	a := batchingApp(store, 10, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	session := newFakeSession(ctx)
+
+	messages, done := startClaim(a, session)
+	messages <- orderMessage(t, 0, orderNumbered(0))
+	messages <- orderMessage(t, 1, orderNumbered(1))
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ConsumeClaim() error = %v", err)
+	}
+
+	assertMarked(t, session, nil, 0)
+	if _, exist, _ := store.FindOrderById(orderNumbered(0).OrderUID); exist {
+		t.Error("Unflushed order should not be stored")
+	}
+}
+
+func TestConsumeBatches_PermanentFailureDeadLettersOnlyBadOrder(t *testing.T) {
+	store := rejectingStore{MemoryStore: storage.NewMemoryStore(), uid: orderNumbered(1).OrderUID}
+	a := batchingApp(store, 3, time.Hour)
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		i := slices.IndexFunc(msg.Headers, func(header sarama.RecordHeader) bool {
+			return string(header.Key) == dlq.HeaderSourceOffset
+		})
+		if i < 0 || string(msg.Headers[i].Value) != "1" {
+			return fmt.Errorf("dead-lettered message is not offset 1: %v", msg.Headers)
+		}
+		return nil
+	})
+	defer producer.Close()
+	a.dlq = dlq.NewQueueFromClient(nil, producer, "orders.dlq", "orders")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	session := newFakeSession(ctx)
+
+	messages, done := startClaim(a, session)
+	for i := range 3 {
+		messages <- orderMessage(t, int64(i), orderNumbered(i))
+	}
+	waitMarked(t, session, 1)
+	cancel()
+	<-done
+
+	assertMarked(t, session, []int64{2}, 1)
+	for _, i := range []int{0, 2} {
+		if _, exist, _ := store.FindOrderById(orderNumbered(i).OrderUID); !exist {
+			t.Errorf("Order %d should be stored one by one", i)
+		}
+	}
+}
+
+func TestConsumeBatches_TransientFailureIsNotMarked(t *testing.T) {
+	a := batchingApp(failingStore{err: errTransient}, 2, time.Hour)
+	session := newFakeSession(context.Background())
+
+	err := consumeAll(a, session, orderMessage(t, 0, orderNumbered(0)), orderMessage(t, 1, orderNumbered(1)))
+	if err == nil {
+		t.Fatal("ConsumeClaim() should end the session")
+	}
+	assertMarked(t, session, nil, 0)
+}
+
+func TestConsumeBatches_TransientFailureDoesNotDeadLetter(t *testing.T) {
+	invalid := orderNumbered(0)
+	invalid.Payment.Amount++
+	a := batchingApp(failingStore{err: errTransient}, 2, time.Hour)
+	// No send is expected: the invalid order is dead-lettered only once the
+	// batch is stored, or it would be sent again when the batch is replayed.
+	withDLQ(t, a, 0, nil)
+	session := newFakeSession(context.Background())
+
+	err := consumeAll(a, session, orderMessage(t, 0, invalid), orderMessage(t, 1, orderNumbered(1)))
+	if err == nil {
+		t.Fatal("ConsumeClaim() should end the session")
+	}
+	assertMarked(t, session, nil, 0)
+}
+
+func TestConsumeBatches_FallbackCommitsProcessedMessages(t *testing.T) {
+	a := batchingApp(failingStore{err: errPermanent}, 3, time.Hour)
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	producer.ExpectSendMessageAndFail(errors.New("broker is down"))
+	defer producer.Close()
+	a.dlq = dlq.NewQueueFromClient(nil, producer, "orders.dlq", "orders")
+	session := newFakeSession(context.Background())
+
+	err := consumeAll(a, session,
+		orderMessage(t, 0, orderNumbered(0)), orderMessage(t, 1, orderNumbered(1)), orderMessage(t, 2, orderNumbered(2)))
+	if err == nil {
+		t.Fatal("ConsumeClaim() should end the session")
+	}
+	// Offset 0 is already dead-lettered, so only offsets from 1 are replayed.
+	assertMarked(t, session, []int64{0}, 1)
+}
@@ -0,0 +1,210 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"test-task/internal/storage"
+	"test-task/internal/validation"
+	"test-task/pkg/models"
+
+	"github.com/IBM/sarama"
+)
+
+const consumerRestartDelay = time.Second
+
+// runConsumer keeps the App joined to the consumer group until Close is called.
+// Consume returns on every rebalance, so it has to be called in a loop.
+func (a *App) runConsumer() {
+	defer close(a.doneChan)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-a.stopChan
+		cancel()
+	}()
+
+	go func() {
+		for err := range a.consumer.Errors() {
+			log.Printf("consumer error: %v", err)
+		}
+	}()
+
+	handler := &orderHandler{app: a}
+	for {
+		err := a.consumer.Consume(ctx, []string{a.topic}, handler)
+		if ctx.Err() != nil {
+			log.Println("consumer stopped by context")
+			return
+		}
+		if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+			log.Println("consumer group closed")
+			return
+		}
+		if err != nil {
+			log.Printf("consume error: %v", err)
+			select {
+			case <-time.After(consumerRestartDelay):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// orderHandler implements sarama.ConsumerGroupHandler for the orders topic.
+type orderHandler struct {
+	app *App
+}
+
+func (h *orderHandler) Setup(session sarama.ConsumerGroupSession) error {
+	log.Printf("consumer group session started, member %s, claims %v",
+		session.MemberID(), session.Claims())
+	return nil
+}
+
+func (h *orderHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	log.Printf("consumer group session finished, member %s", session.MemberID())
+	return nil
+}
+
+func (h *orderHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	log.Printf("consuming partition %d of %s from offset %d",
+		claim.Partition(), claim.Topic(), claim.InitialOffset())
+
+	if h.app.batchSize > 1 {
+		return h.consumeBatches(session, claim)
+	}
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				log.Printf("messages channel of partition %d closed", claim.Partition())
+				return nil
+			}
+			if err := h.app.processMessage(session.Context(), msg); err != nil {
+				log.Printf("partition %d offset %d is not processed: %v",
+					msg.Partition, msg.Offset, err)
+				return endSession(session, err)
+			}
+			session.MarkMessage(msg, "")
+			session.Commit()
+
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// endSession is returned from ConsumeClaim when a message is not processed.
+// The offset is left unmarked. Returning an error ends the whole session, and
+// the next one resumes from the last committed offset, so the message is read
+// again instead of being dropped.
+func endSession(session sarama.ConsumerGroupSession, err error) error {
+	select {
+	case <-time.After(consumerRestartDelay):
+	case <-session.Context().Done():
+	}
+	return err
+}
+
+// processMessage returns nil once the message may be committed: either the
+// order is stored or the message is forwarded to the dead-letter topic.
+func (a *App) processMessage(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	order, reason := decodeOrder(msg)
+	if reason != nil {
+		return a.rejectMessage(msg, reason)
+	}
+	return a.storeMessage(ctx, msg, order)
+}
+
+// decodeOrder parses and validates the message. The error tells why the
+// message is invalid.
+func decodeOrder(msg *sarama.ConsumerMessage) (*models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(msg.Value, &order); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if err := validation.ValidateOrder(&order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// rejectMessage forwards an invalid message to the dead-letter topic, or
+// skips it if there is none.
+func (a *App) rejectMessage(msg *sarama.ConsumerMessage, reason error) error {
+	if a.dlq == nil {
+		log.Printf("invalid message, skipping partition %d offset %d: %v",
+			msg.Partition, msg.Offset, reason)
+		return nil
+	}
+	return a.deadLetter(msg, reason)
+}
+
+// storeMessage stores a decoded order; see processMessage.
+func (a *App) storeMessage(ctx context.Context, msg *sarama.ConsumerMessage, order *models.Order) error {
+	var outcome storage.InsertOutcome
+	err := a.retryPaused(ctx, msg, "store order "+order.OrderUID, func() error {
+		var err error
+		outcome, err = a.store.InsertToDB(order)
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("store order %s: %w", order.OrderUID, err)
+		// Transient errors that outlived the retries are not the message's
+		// fault, so it is replayed instead of being dead-lettered.
+		if storage.IsTransient(err) {
+			return err
+		}
+		if a.dlq == nil {
+			log.Printf("order is not stored, skipping partition %d offset %d: %v",
+				msg.Partition, msg.Offset, err)
+			return nil
+		}
+		return a.deadLetter(msg, err)
+	}
+
+	log.Printf("processed order %s from partition %d offset %d: %v",
+		order.OrderUID, msg.Partition, msg.Offset, outcome)
+	return nil
+}
+
+// retryPaused runs fn, retrying transient failures. The partition of msg is
+// paused while retrying so no more messages are fetched for it meanwhile.
+func (a *App) retryPaused(ctx context.Context, msg *sarama.ConsumerMessage, what string, fn func() error) error {
+	partitions := map[string][]int32{msg.Topic: {msg.Partition}}
+	paused := false
+	defer func() {
+		if paused {
+			a.consumer.Resume(partitions)
+			log.Printf("resumed partition %d", msg.Partition)
+		}
+	}()
+
+	return a.retryPolicy.Do(ctx, storage.IsTransient,
+		func(attempt int, err error) {
+			if !paused {
+				a.consumer.Pause(partitions)
+				paused = true
+				log.Printf("paused partition %d while retrying", msg.Partition)
+			}
+			log.Printf("%s failed on attempt %d, retrying: %v", what, attempt, err)
+		},
+		fn)
+}
+
+func (a *App) deadLetter(msg *sarama.ConsumerMessage, reason error) error {
+	if err := a.dlq.Send(msg, reason); err != nil {
+		return fmt.Errorf("forward to dead-letter topic: %w", err)
+	}
+	return nil
+}
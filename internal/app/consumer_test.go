@@ -0,0 +1,331 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task/internal/dlq"
+	"test-task/internal/retry"
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+// noRetries fails on the first error, so no partition is paused.
+var noRetries = retry.Policy{MaxAttempts: 1}
+
+func orderMessage(t *testing.T, offset int64, order models.Order) *sarama.ConsumerMessage {
+	t.Helper()
+	value, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Topic: "orders", Partition: 0, Offset: offset, Value: value}
+}
+
+// fakeSession records the offsets marked and the commits made through it.
+type fakeSession struct {
+	ctx context.Context
+
+	mu      sync.Mutex
+	marked  []int64
+	commits int
+}
+
+func newFakeSession(ctx context.Context) *fakeSession {
+	return &fakeSession{ctx: ctx}
+}
+
+func (s *fakeSession) Claims() map[string][]int32                        { return nil }
+func (s *fakeSession) MemberID() string                                  { return "member" }
+func (s *fakeSession) GenerationID() int32                               { return 1 }
+func (s *fakeSession) MarkOffset(string, int32, int64, string)           {}
+func (s *fakeSession) ResetOffset(string, int32, int64, string)          {}
+func (s *fakeSession) Context() context.Context                          { return s.ctx }
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, _ string) { s.record(msg.Offset, 0) }
+func (s *fakeSession) Commit()                                           { s.record(-1, 1) }
+
+func (s *fakeSession) record(offset int64, commits int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if offset >= 0 {
+		s.marked = append(s.marked, offset)
+	}
+	s.commits += commits
+}
+
+// state returns the marked offsets and the number of commits so far.
+func (s *fakeSession) state() ([]int64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.marked), s.commits
+}
+
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func newFakeClaim() *fakeClaim {
+	return &fakeClaim{messages: make(chan *sarama.ConsumerMessage, 16)}
+}
+
+func (c *fakeClaim) Topic() string                            { return "orders" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+// consumeAll feeds messages to ConsumeClaim, closes the claim and returns
+// what ConsumeClaim returned.
+func consumeAll(a *App, session *fakeSession, messages ...*sarama.ConsumerMessage) error {
+	claim := newFakeClaim()
+	for _, msg := range messages {
+		claim.messages <- msg
+	}
+	close(claim.messages)
+	return (&orderHandler{app: a}).ConsumeClaim(session, claim)
+}
+
+// withDLQ gives the App a dead-letter topic on a mock producer that expects
+// sends messages, failing them with sendErr if it is set.
+func withDLQ(t *testing.T, a *App, sends int, sendErr error) {
+	t.Helper()
+	producer := mocks.NewSyncProducer(t, nil)
+	for range sends {
+		if sendErr != nil {
+			producer.ExpectSendMessageAndFail(sendErr)
+		} else {
+			producer.ExpectSendMessageAndSucceed()
+		}
+	}
+	a.dlq = dlq.NewQueueFromClient(nil, producer, "orders.dlq", "orders")
+	t.Cleanup(func() { producer.Close() })
+}
+
+func otherOrder() models.Order {
+	order := testOrder()
+	order.OrderUID = "other"
+	return order
+}
+
+func assertMarked(t *testing.T, session *fakeSession, wantMarked []int64, wantCommits int) {
+	t.Helper()
+	marked, commits := session.state()
+	if !slices.Equal(marked, wantMarked) {
+		t.Errorf("Marked offsets %v, wanted %v", marked, wantMarked)
+	}
+	if commits != wantCommits {
+		t.Errorf("Committed %d times, wanted %d", commits, wantCommits)
+	}
+}
+
+func TestConsumeClaim_StoredMessagesAreCommitted(t *testing.T) {
+	store := storage.NewMemoryStore()
+	a := &App{store: store, retryPolicy: noRetries}
+	session := newFakeSession(context.Background())
+
+	err := consumeAll(a, session, orderMessage(t, 0, testOrder()), orderMessage(t, 1, otherOrder()))
+	if err != nil {
+		t.Fatalf("ConsumeClaim() error = %v", err)
+	}
+
+	assertMarked(t, session, []int64{0, 1}, 2)
+	for _, uid := range []string{"b563feb7b2b84b6test", "other"} {
+		if _, exist, _ := store.FindOrderById(uid); !exist {
+			t.Errorf("Order %s is committed but not stored", uid)
+		}
+	}
+}
+
+func TestConsumeClaim_DeadLetteredMessagesAreCommitted(t *testing.T) {
+	invalid := testOrder()
+	invalid.Payment.Amount++
+
+	tests := []struct {
+		name  string
+		store storage.OrderStore
+		msg   *sarama.ConsumerMessage
+	}{
+		{"Unparsable", storage.NewMemoryStore(), &sarama.ConsumerMessage{Topic: "orders", Value: []byte("not json")}},
+		{"Invalid", storage.NewMemoryStore(), orderMessage(t, 0, invalid)},
+		{"NotStored", failingStore{err: errPermanent}, orderMessage(t, 0, testOrder())},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{store: tt.store, retryPolicy: noRetries}
+			withDLQ(t, a, 1, nil)
+			session := newFakeSession(context.Background())
+
+			if err := consumeAll(a, session, tt.msg); err != nil {
+				t.Fatalf("ConsumeClaim() error = %v", err)
+			}
+			assertMarked(t, session, []int64{0}, 1)
+		})
+	}
+}
+
+func TestConsumeClaim_FailureLeavesOffsetUnmarked(t *testing.T) {
+	tests := []struct {
+		name  string
+		store storage.OrderStore
+		// dlqErr, if set, makes forwarding to the dead-letter topic fail.
+		dlqErr error
+	}{
+		{"StoreUnavailable", failingStore{err: errTransient}, nil},
+		{"DLQUnavailable", failingStore{err: errPermanent}, errors.New("broker is down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{store: tt.store, retryPolicy: noRetries}
+			if tt.dlqErr != nil {
+				withDLQ(t, a, 1, tt.dlqErr)
+			}
+			session := newFakeSession(context.Background())
+
+			err := consumeAll(a, session, orderMessage(t, 0, testOrder()), orderMessage(t, 1, otherOrder()))
+			if err == nil {
+				t.Fatal("ConsumeClaim() should end the session")
+			}
+			assertMarked(t, session, nil, 0)
+		})
+	}
+}
+
+func TestProcessMessage_WithoutDLQ(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   storage.OrderStore
+		wantErr bool
+	}{
+		{"PermanentErrorIsSkipped", failingStore{err: errPermanent}, false},
+		{"TransientErrorIsReplayed", failingStore{err: errTransient}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &App{store: tt.store, retryPolicy: noRetries}
+			err := a.processMessage(context.Background(), orderMessage(t, 0, testOrder()))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processMessage() error = %v, wanted error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessBatch_WithoutDLQ(t *testing.T) {
+	a := &App{store: failingStore{err: errPermanent}, retryPolicy: noRetries}
+	batch := []*sarama.ConsumerMessage{
+		orderMessage(t, 0, testOrder()),
+		orderMessage(t, 1, testOrder()),
+	}
+
+	if _, err := a.processBatch(context.Background(), batch); err != nil {
+		t.Errorf("Batch failing permanently should be skipped without DLQ, got %v", err)
+	}
+}
+
+// fakeGroup records the partitions paused and resumed through it.
+type fakeGroup struct {
+	sarama.ConsumerGroup
+
+	mu      sync.Mutex
+	paused  map[int32]bool
+	pauses  int
+	resumes int
+}
+
+func (g *fakeGroup) Pause(partitions map[string][]int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.paused == nil {
+		g.paused = make(map[int32]bool)
+	}
+	for _, partition := range partitions["orders"] {
+		g.paused[partition] = true
+	}
+	g.pauses++
+}
+
+func (g *fakeGroup) Resume(partitions map[string][]int32) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, partition := range partitions["orders"] {
+		delete(g.paused, partition)
+	}
+	g.resumes++
+}
+
+func (g *fakeGroup) isPaused(partition int32) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused[partition]
+}
+
+// flakyStore fails the first failures inserts transiently and records
+// whether the partition was paused when an insert was retried.
+type flakyStore struct {
+	*storage.MemoryStore
+	group    *fakeGroup
+	failures int
+
+	attempts      int
+	pausedOnRetry bool
+}
+
+func (store *flakyStore) InsertToDB(order *models.Order) (storage.InsertOutcome, error) {
+	store.attempts++
+	if store.attempts > 1 {
+		store.pausedOnRetry = store.group.isPaused(0)
+	}
+	if store.failures > 0 {
+		store.failures--
+		return 0, errTransient
+	}
+	return store.MemoryStore.InsertToDB(order)
+}
+
+func TestProcessMessage_PausesPartitionWhileRetrying(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		wantPauses int
+	}{
+		{"Retried", 1, 1},
+		{"FirstAttempt", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &fakeGroup{}
+			store := &flakyStore{MemoryStore: storage.NewMemoryStore(), group: group, failures: tt.failures}
+			a := &App{
+				store:       store,
+				consumer:    group,
+				retryPolicy: retry.Policy{MaxAttempts: 2, InitialInterval: time.Millisecond},
+			}
+
+			if err := a.processMessage(context.Background(), orderMessage(t, 0, testOrder())); err != nil {
+				t.Fatalf("processMessage() error = %v", err)
+			}
+
+			if tt.failures > 0 && !store.pausedOnRetry {
+				t.Error("Partition should be paused while the insert is retried")
+			}
+			if group.isPaused(0) {
+				t.Error("Partition should be resumed after the insert")
+			}
+			if group.pauses != tt.wantPauses || group.resumes != tt.wantPauses {
+				t.Errorf("Paused %d and resumed %d times, wanted %d", group.pauses, group.resumes, tt.wantPauses)
+			}
+		})
+	}
+}
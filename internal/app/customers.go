@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/gorilla/mux"
+)
+
+type customerOrders struct {
+	Summary    storage.CustomerSummary `json:"summary"`
+	Orders     []models.Order          `json:"orders"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// CustomerOrders serves GET /customers/{customer_id}/orders: the customer's
+// summary and a page of their orders, newest first. Pages work as in
+// ListOrders, with limit and cursor.
+func (a *App) CustomerOrders(w http.ResponseWriter, r *http.Request) {
+	customerID := mux.Vars(r)["customer_id"]
+
+	values := r.URL.Query()
+	limit, err := parseLimit(values.Get("limit"), defaultOrdersLimit, maxOrdersLimit)
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+	query := storage.ListQuery{Limit: limit, Filter: storage.OrderFilter{CustomerID: customerID}}
+	if token := values.Get("cursor"); token != "" {
+		if query.After, err = storage.DecodeCursor(token); err != nil {
+			badRequest(w, r, err.Error())
+			return
+		}
+	}
+
+	summary, err := a.store.CustomerSummary(customerID)
+	if err != nil {
+		failed(w, r, fmt.Sprintf("failed to summarize orders of customer %s", customerID), err)
+		return
+	}
+	if summary.OrderCount == 0 {
+		notFound(w, r, fmt.Sprintf("customer %s has no orders", customerID))
+		return
+	}
+
+	page, err := a.listPage(query)
+	if err != nil {
+		failed(w, r, fmt.Sprintf("failed to list orders of customer %s", customerID), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, customerOrders{
+		Summary:    summary,
+		Orders:     page.Orders,
+		NextCursor: page.NextCursor,
+	})
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"test-task/internal/dlq"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultDLQLimit = 100
+	maxDLQLimit     = 1000
+)
+
+func (a *App) ListDLQ(w http.ResponseWriter, r *http.Request) {
+	if a.dlq == nil {
+		notFound(w, r, "dead-letter topic is disabled")
+		return
+	}
+
+	limit, err := parseLimit(r.URL.Query().Get("limit"), defaultDLQLimit, maxDLQLimit)
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	entries, err := a.dlq.List(limit)
+	if err != nil {
+		failed(w, r, "failed to read dead-letter topic", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (a *App) RedriveDLQ(w http.ResponseWriter, r *http.Request) {
+	if a.dlq == nil {
+		notFound(w, r, "dead-letter topic is disabled")
+		return
+	}
+
+	vars := mux.Vars(r)
+	partition, err := strconv.ParseInt(vars["partition"], 10, 32)
+	if err != nil || partition < 0 {
+		badRequest(w, r, "invalid partition")
+		return
+	}
+	offset, err := strconv.ParseInt(vars["offset"], 10, 64)
+	if err != nil || offset < 0 {
+		badRequest(w, r, "invalid offset")
+		return
+	}
+
+	err = a.dlq.Redrive(int32(partition), offset)
+	if errors.Is(err, dlq.ErrEntryNotFound) {
+		notFound(w, r, "dead-letter entry not found")
+		return
+	}
+	if err != nil {
+		failed(w, r, fmt.Sprintf("failed to redrive entry %d/%d", partition, offset), err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"partition": partition,
+		"offset":    offset,
+		"topic":     a.topic,
+	})
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+
+	"test-task/internal/storage"
+)
+
+// requestIDHeader carries the request id in both directions: a client or a
+// proxy may set it, the response always has it.
+const requestIDHeader = "X-Request-ID"
+
+// Error codes of apiError, one per status the handlers answer with.
+const (
+	codeBadRequest  = "bad_request"
+	codeNotFound    = "not_found"
+	codeNotAllowed  = "method_not_allowed"
+	codeInternal    = "internal_error"
+	codeUnavailable = "unavailable"
+)
+
+// apiError is the body of every error response.
+type apiError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+type requestIDKey struct{}
+
+// RequestID takes the request id from the X-Request-ID header or generates
+// one, echoes it in the response and makes it available to the handlers.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the id RequestID gave the request. Requests that did not
+// pass through it, like those no route matched, get a new one here.
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id, ok := r.Context().Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	id := r.Header.Get(requestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	w.Header().Set(requestIDHeader, id)
+	return id
+}
+
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		log.Printf("Unable to generate request id: %v", err)
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	writeJSON(w, status, apiError{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID(w, r),
+	})
+}
+
+func badRequest(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusBadRequest, codeBadRequest, message)
+}
+
+func notFound(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusNotFound, codeNotFound, message)
+}
+
+// failed logs err and answers 503 if it is transient, so the client may
+// retry, or 500 otherwise. The message is shown to the client instead of err.
+func failed(w http.ResponseWriter, r *http.Request, message string, err error) {
+	id := requestID(w, r)
+	log.Printf("Request %s: %s: %v", id, message, err)
+	if storage.IsTransient(err) {
+		w.Header().Set("Retry-After", "1")
+		writeError(w, r, http.StatusServiceUnavailable, codeUnavailable, message)
+		return
+	}
+	writeError(w, r, http.StatusInternalServerError, codeInternal, message)
+}
+
+// NotFound answers the requests no route matched.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	notFound(w, r, "no such endpoint")
+}
+
+// MethodNotAllowed answers the requests to a known path with a wrong method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusMethodNotAllowed, codeNotAllowed, r.Method+" is not allowed here")
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// decodeError checks the status and the headers of an error response and
+// returns its body.
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder, wantStatus int, wantCode string) apiError {
+	t.Helper()
+	if rec.Code != wantStatus {
+		t.Fatalf("Status = %d, wanted %d; body: %s", rec.Code, wantStatus, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, wanted application/json", got)
+	}
+	var body apiError
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Error body is not JSON: %v", err)
+	}
+	if body.Code != wantCode {
+		t.Errorf("Code = %q, wanted %q", body.Code, wantCode)
+	}
+	if body.Message == "" {
+		t.Error("Message should not be empty")
+	}
+	if body.RequestID == "" || body.RequestID != rec.Header().Get(requestIDHeader) {
+		t.Errorf("Request id %q should match the %s header %q",
+			body.RequestID, requestIDHeader, rec.Header().Get(requestIDHeader))
+	}
+	return body
+}
+
+func TestRequestID_KeepsClientID(t *testing.T) {
+	handler := RequestID(http.HandlerFunc(NotFound))
+	req := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	req.Header.Set(requestIDHeader, "client-id")
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, req)
+
+	body := decodeError(t, rec, http.StatusNotFound, codeNotFound)
+	if body.RequestID != "client-id" {
+		t.Errorf("Request id = %q, wanted client-id", body.RequestID)
+	}
+}
+
+func TestRequestID_GeneratesID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = requestID(w, r)
+	}))
+	rec := httptest.NewRecorder()
+
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if seen == "" {
+		t.Fatal("Handler should see a generated request id")
+	}
+	if got := rec.Header().Get(requestIDHeader); got != seen {
+		t.Errorf("%s header = %q, wanted %q", requestIDHeader, got, seen)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"Transient", context.DeadlineExceeded, http.StatusServiceUnavailable, codeUnavailable},
+		{"Permanent", errors.New("syntax error"), http.StatusInternalServerError, codeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			failed(rec, httptest.NewRequest(http.MethodGet, "/", nil), "failed to do it", tt.err)
+
+			body := decodeError(t, rec, tt.wantStatus, tt.wantCode)
+			if body.Message != "failed to do it" {
+				t.Errorf("Message = %q, the error should not leak to the client", body.Message)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	MethodNotAllowed(rec, httptest.NewRequest(http.MethodPut, "/orders", nil))
+
+	decodeError(t, rec, http.StatusMethodNotAllowed, codeNotAllowed)
+}
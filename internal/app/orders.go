@@ -0,0 +1,206 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultOrdersLimit = 50
+	maxOrdersLimit     = 500
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type ordersPage struct {
+	Orders []models.Order `json:"orders"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ListOrders serves GET /orders: orders from the newest to the oldest, with
+// optional filters, a page at a time. The next page is requested with the
+// next_cursor of the previous one and the same filters.
+func (a *App) ListOrders(w http.ResponseWriter, r *http.Request) {
+	query, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	page, err := a.listPage(query)
+	if err != nil {
+		failed(w, r, "failed to list orders", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// listPage asks for one order more than the limit to learn whether there is
+// a next page.
+func (a *App) listPage(query storage.ListQuery) (ordersPage, error) {
+	limit := query.Limit
+	query.Limit++
+	orders, err := a.store.ListOrders(query)
+	if err != nil {
+		return ordersPage{}, err
+	}
+
+	page := ordersPage{Orders: orders}
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		page.NextCursor = storage.CursorOf(&page.Orders[limit-1]).Encode()
+	}
+	if page.Orders == nil {
+		page.Orders = []models.Order{}
+	}
+	return page, nil
+}
+
+func parseListQuery(values url.Values) (storage.ListQuery, error) {
+	query := storage.ListQuery{Limit: defaultOrdersLimit}
+
+	limit, err := parseLimit(values.Get("limit"), defaultOrdersLimit, maxOrdersLimit)
+	if err != nil {
+		return query, err
+	}
+	query.Limit = limit
+
+	if token := values.Get("cursor"); token != "" {
+		query.After, err = storage.DecodeCursor(token)
+		if err != nil {
+			return query, err
+		}
+	}
+
+	filter := &query.Filter
+	filter.CustomerID = values.Get("customer_id")
+	filter.DeliveryService = values.Get("delivery_service")
+	filter.Locale = values.Get("locale")
+	filter.Currency = values.Get("currency")
+	if value := values.Get("sm_id"); value != "" {
+		smID, err := strconv.Atoi(value)
+		if err != nil {
+			return query, fmt.Errorf("sm_id must be an integer")
+		}
+		filter.SmID = &smID
+	}
+	if filter.CreatedFrom, err = parseTime("from", values.Get("from")); err != nil {
+		return query, err
+	}
+	if filter.CreatedTo, err = parseTime("to", values.Get("to")); err != nil {
+		return query, err
+	}
+	return query, nil
+}
+
+// parseLimit returns defaultLimit for an empty value and caps the limit at
+// maxLimit.
+func parseLimit(value string, defaultLimit, maxLimit int) (int, error) {
+	if value == "" {
+		return defaultLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	return min(limit, maxLimit), nil
+}
+
+// parseTime accepts RFC 3339 timestamps and plain dates, which mean midnight
+// UTC. An empty value is the zero time.
+func parseTime(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	if parsed, err := time.Parse(time.DateOnly, value); err == nil {
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp or a YYYY-MM-DD date", name)
+}
+
+type trackMatch struct {
+	Order models.Order `json:"order"`
+	// OrderMatched is set when the order's own track_number matches.
+	OrderMatched bool `json:"order_matched"`
+	// MatchedItems are the indexes of the order's items with the track number.
+	MatchedItems []int `json:"matched_items"`
+}
+
+type trackOrders struct {
+	TrackNumber string       `json:"track_number"`
+	Orders      []trackMatch `json:"orders"`
+}
+
+// GetOrdersByTrack serves GET /orders/by-track/{track_number}: the orders
+// owning the track number, with the items carrying it pointed out.
+func (a *App) GetOrdersByTrack(w http.ResponseWriter, r *http.Request) {
+	trackNumber := mux.Vars(r)["track_number"]
+	log.Printf("Searching by track number: %v", trackNumber)
+
+	orders, err := a.store.FindOrdersByTrack(trackNumber)
+	if err != nil {
+		failed(w, r, "failed to find orders", err)
+		return
+	}
+	if len(orders) == 0 {
+		notFound(w, r, fmt.Sprintf("no orders with track number %s", trackNumber))
+		return
+	}
+
+	response := trackOrders{TrackNumber: trackNumber, Orders: make([]trackMatch, 0, len(orders))}
+	for _, order := range orders {
+		match := trackMatch{
+			Order:        order,
+			OrderMatched: order.TrackNumber == trackNumber,
+			MatchedItems: []int{},
+		}
+		for i := range order.Items {
+			if order.Items[i].TrackNumber == trackNumber {
+				match.MatchedItems = append(match.MatchedItems, i)
+			}
+		}
+		response.Orders = append(response.Orders, match)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// SearchOrders serves GET /orders/search?q=...: summaries of the orders
+// matching q by recipient, address, item, phone or email, best match first.
+func (a *App) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	values := r.URL.Query()
+	text := strings.TrimSpace(values.Get("q"))
+	if text == "" {
+		badRequest(w, r, "q is required")
+		return
+	}
+	limit, err := parseLimit(values.Get("limit"), defaultSearchLimit, maxSearchLimit)
+	if err != nil {
+		badRequest(w, r, err.Error())
+		return
+	}
+
+	summaries, err := a.store.SearchOrders(storage.SearchQuery{Text: text, Limit: limit})
+	if err != nil {
+		failed(w, r, "failed to search orders", err)
+		return
+	}
+	if summaries == nil {
+		summaries = []storage.OrderSummary{}
+	}
+	writeJSON(w, http.StatusOK, summaries)
+}
@@ -0,0 +1,286 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"test-task/internal/storage"
+	"test-task/pkg/models"
+
+	"github.com/gorilla/mux"
+)
+
+// failingStore fails every insert and every lookup the handlers make with err.
+type failingStore struct {
+	storage.OrderStore
+	err error
+}
+
+func (store failingStore) InsertToDB(*models.Order) (storage.InsertOutcome, error) {
+	return 0, store.err
+}
+
+func (store failingStore) InsertBatch([]*models.Order) ([]storage.InsertOutcome, error) {
+	return nil, store.err
+}
+
+func (store failingStore) FindOrderById(string) (models.Order, bool, error) {
+	return models.Order{}, false, store.err
+}
+
+func (store failingStore) FindOrdersByTrack(string) ([]models.Order, error) {
+	return nil, store.err
+}
+
+func (store failingStore) ListOrders(storage.ListQuery) ([]models.Order, error) {
+	return nil, store.err
+}
+
+func (store failingStore) SearchOrders(storage.SearchQuery) ([]storage.OrderSummary, error) {
+	return nil, store.err
+}
+
+func (store failingStore) CustomerSummary(string) (storage.CustomerSummary, error) {
+	return storage.CustomerSummary{}, store.err
+}
+
+var (
+	errTransient = context.DeadlineExceeded
+	errPermanent = errors.New("syntax error")
+)
+
+// testOrder is a valid order, so it can go through the consumer as well.
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func appWithOrder(t *testing.T) *App {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	order := testOrder()
+	if _, err := store.InsertToDB(&order); err != nil {
+		t.Fatal(err)
+	}
+	return &App{store: store}
+}
+
+// serve calls handler with the route variables vars, as the router would.
+func serve(handler http.HandlerFunc, target string, vars map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	if vars != nil {
+		req = mux.SetURLVars(req, vars)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func decodeOK(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, wanted 200; body: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, wanted application/json", got)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("Body is not JSON: %v", err)
+	}
+}
+
+// testStoreFailures checks that handler answers 503 to transient store errors
+// and 500 to the rest.
+func testStoreFailures(t *testing.T, handler func(a *App) http.HandlerFunc, target string, vars map[string]string) {
+	t.Run("Unavailable", func(t *testing.T) {
+		a := &App{store: failingStore{err: errTransient}}
+		decodeError(t, serve(handler(a), target, vars), http.StatusServiceUnavailable, codeUnavailable)
+	})
+	t.Run("InternalError", func(t *testing.T) {
+		a := &App{store: failingStore{err: errPermanent}}
+		decodeError(t, serve(handler(a), target, vars), http.StatusInternalServerError, codeInternal)
+	})
+}
+
+func TestGetOrderById(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.GetOrderById }
+
+	t.Run("Found", func(t *testing.T) {
+		var order models.Order
+		rec := serve(appWithOrder(t).GetOrderById, "/order/b563feb7b2b84b6test",
+			map[string]string{"order_uid": "b563feb7b2b84b6test"})
+		decodeOK(t, rec, &order)
+		if order.OrderUID != "b563feb7b2b84b6test" || order.Payment.Amount != 1817 {
+			t.Errorf("Got order %+v", order)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		rec := serve(appWithOrder(t).GetOrderById, "/order/missing", map[string]string{"order_uid": "missing"})
+		decodeError(t, rec, http.StatusNotFound, codeNotFound)
+	})
+	testStoreFailures(t, handler, "/order/b563feb7b2b84b6test", map[string]string{"order_uid": "b563feb7b2b84b6test"})
+}
+
+func TestListOrders(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.ListOrders }
+
+	t.Run("Page", func(t *testing.T) {
+		var page ordersPage
+		decodeOK(t, serve(appWithOrder(t).ListOrders, "/orders?limit=1", nil), &page)
+		if len(page.Orders) != 1 || page.NextCursor != "" {
+			t.Errorf("Got %d orders and cursor %q, wanted the only order", len(page.Orders), page.NextCursor)
+		}
+	})
+	for _, target := range []string{"/orders?limit=0", "/orders?cursor=broken", "/orders?sm_id=x", "/orders?from=yesterday"} {
+		t.Run("BadRequest "+target, func(t *testing.T) {
+			decodeError(t, serve(appWithOrder(t).ListOrders, target, nil), http.StatusBadRequest, codeBadRequest)
+		})
+	}
+	testStoreFailures(t, handler, "/orders", nil)
+}
+
+func TestGetOrdersByTrack(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.GetOrdersByTrack }
+
+	t.Run("Found", func(t *testing.T) {
+		var response trackOrders
+		rec := serve(appWithOrder(t).GetOrdersByTrack, "/orders/by-track/WBILMTESTTRACK",
+			map[string]string{"track_number": "WBILMTESTTRACK"})
+		decodeOK(t, rec, &response)
+		if len(response.Orders) != 1 || !response.Orders[0].OrderMatched {
+			t.Errorf("Got %+v, wanted the order matched by its own track number", response)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		rec := serve(appWithOrder(t).GetOrdersByTrack, "/orders/by-track/NOTRACK",
+			map[string]string{"track_number": "NOTRACK"})
+		decodeError(t, rec, http.StatusNotFound, codeNotFound)
+	})
+	testStoreFailures(t, handler, "/orders/by-track/WBILMTESTTRACK", map[string]string{"track_number": "WBILMTESTTRACK"})
+}
+
+func TestSearchOrders(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.SearchOrders }
+
+	t.Run("Found", func(t *testing.T) {
+		var summaries []storage.OrderSummary
+		decodeOK(t, serve(appWithOrder(t).SearchOrders, "/orders/search?q=testov", nil), &summaries)
+		if len(summaries) != 1 || summaries[0].OrderUID != "b563feb7b2b84b6test" {
+			t.Errorf("Got %+v, wanted the test order", summaries)
+		}
+	})
+	t.Run("NothingFound", func(t *testing.T) {
+		var summaries []storage.OrderSummary
+		decodeOK(t, serve(appWithOrder(t).SearchOrders, "/orders/search?q=nonexistent", nil), &summaries)
+		if summaries == nil || len(summaries) != 0 {
+			t.Errorf("Got %+v, wanted an empty list", summaries)
+		}
+	})
+	for _, target := range []string{"/orders/search", "/orders/search?q=+", "/orders/search?q=testov&limit=-1"} {
+		t.Run("BadRequest "+target, func(t *testing.T) {
+			decodeError(t, serve(appWithOrder(t).SearchOrders, target, nil), http.StatusBadRequest, codeBadRequest)
+		})
+	}
+	testStoreFailures(t, handler, "/orders/search?q=testov", nil)
+}
+
+func TestCustomerOrders(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.CustomerOrders }
+
+	t.Run("Found", func(t *testing.T) {
+		var response customerOrders
+		rec := serve(appWithOrder(t).CustomerOrders, "/customers/test/orders", map[string]string{"customer_id": "test"})
+		decodeOK(t, rec, &response)
+		if response.Summary.OrderCount != 1 || len(response.Orders) != 1 {
+			t.Errorf("Got %+v, wanted the customer's only order", response)
+		}
+	})
+	t.Run("NotFound", func(t *testing.T) {
+		rec := serve(appWithOrder(t).CustomerOrders, "/customers/nobody/orders", map[string]string{"customer_id": "nobody"})
+		decodeError(t, rec, http.StatusNotFound, codeNotFound)
+	})
+	t.Run("BadRequest", func(t *testing.T) {
+		rec := serve(appWithOrder(t).CustomerOrders, "/customers/test/orders?limit=x", map[string]string{"customer_id": "test"})
+		decodeError(t, rec, http.StatusBadRequest, codeBadRequest)
+	})
+	testStoreFailures(t, handler, "/customers/test/orders", map[string]string{"customer_id": "test"})
+}
+
+func TestCreateOrders(t *testing.T) {
+	handler := func(a *App) http.HandlerFunc { return a.CreateOrders }
+
+	t.Run("Created", func(t *testing.T) {
+		store := storage.NewMemoryStore()
+		a := &App{store: store}
+		var orders []models.Order
+		decodeOK(t, serve(a.CreateOrders, "/add", nil), &orders)
+		if len(orders) != 2 {
+			t.Fatalf("Got %d orders, wanted 2", len(orders))
+		}
+		for _, order := range orders {
+			if _, exist, _ := store.FindOrderById(order.OrderUID); !exist {
+				t.Errorf("Order %s is returned but not stored", order.OrderUID)
+			}
+		}
+	})
+	testStoreFailures(t, handler, "/add", nil)
+}
+
+func TestCacheDisabled(t *testing.T) {
+	a := &App{store: storage.NewMemoryStore()}
+	rec := serve(a.CacheStats, "/admin/cache/stats", nil)
+	decodeError(t, rec, http.StatusNotFound, codeNotFound)
+}
+
+func TestDLQDisabled(t *testing.T) {
+	a := &App{store: storage.NewMemoryStore()}
+	rec := serve(a.ListDLQ, "/dlq", nil)
+	decodeError(t, rec, http.StatusNotFound, codeNotFound)
+}
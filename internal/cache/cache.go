@@ -1,59 +1,339 @@
 package cache
 
 import (
-	"container/list"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"test-task/pkg/models"
 )
 
+const (
+	maxShards = 16
+	// Small caches keep a single shard so eviction follows the policy exactly.
+	minShardCapacity = 64
+)
+
+// Options bound the cache. Capacity is required; MaxBytes and TTL are
+// optional and disabled when zero.
+type Options struct {
+	// Capacity is the maximum number of cached orders.
+	Capacity int
+	// MaxBytes is the memory budget as estimated by EstimateSize.
+	MaxBytes int64
+	// TTL is the default time an order stays cached after it is added.
+	TTL time.Duration
+	// Policy selects the eviction policy, LRU by default.
+	Policy PolicyName
+}
+
+// Cache is a cache of orders safe for concurrent use. Keys are spread over
+// independent shards, each with its own lock and eviction policy, so parallel
+// requests for different orders rarely wait for each other. Every shard gets
+// an equal part of the capacity and of the memory budget.
 type Cache struct {
-	capacity  int
-	cacheMap  map[string]*list.Element
-	cacheList *list.List
+	shards   []*shard
+	capacity int
+	maxBytes int64
+	ttl      time.Duration
+	policy   PolicyName
+	now      func() time.Time
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+}
+
+// Stats is a point-in-time view of the cache counters. Counters are
+// cumulative since the cache was created.
+type Stats struct {
+	Policy      PolicyName `json:"policy"`
+	Hits        int64      `json:"hits"`
+	Misses      int64      `json:"misses"`
+	HitRatio    float64    `json:"hit_ratio"`
+	Evictions   int64      `json:"evictions"`
+	Expirations int64      `json:"expirations"`
+	Size        int        `json:"size"`
+	Capacity    int        `json:"capacity"`
+	Bytes       int64      `json:"bytes"`
+	MaxBytes    int64      `json:"max_bytes"`
+}
+
+type shard struct {
+	mu       sync.Mutex
+	capacity int
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*entry
+	policy   Policy
+}
+
+type entry struct {
+	order *models.Order
+	size  int64
+	// expiresAt is zero for entries without TTL.
+	expiresAt time.Time
 }
 
+// CreateCache creates an LRU cache bounded only by the number of orders.
 func CreateCache(capacity int) *Cache {
-	return &Cache{
-		capacity:  capacity,
-		cacheMap:  make(map[string]*list.Element),
-		cacheList: list.New(),
+	cache, _ := CreateCacheWithOptions(Options{Capacity: capacity, Policy: PolicyLRU})
+	return cache
+}
+
+func CreateCacheWithOptions(options Options) (*Cache, error) {
+	capacity := options.Capacity
+	shardCount := min(max(capacity/minShardCapacity, 1), maxShards)
+
+	policyName := options.Policy
+	if policyName == "" {
+		policyName = PolicyLRU
+	}
+
+	cache := &Cache{
+		shards:   make([]*shard, shardCount),
+		capacity: capacity,
+		maxBytes: options.MaxBytes,
+		ttl:      options.TTL,
+		policy:   policyName,
+		now:      time.Now,
 	}
+	for i := range cache.shards {
+		shardCapacity := capacity / shardCount
+		if i < capacity%shardCount {
+			shardCapacity++
+		}
+		policy, err := NewPolicy(policyName, shardCapacity)
+		if err != nil {
+			return nil, err
+		}
+		cache.shards[i] = &shard{
+			capacity: shardCapacity,
+			maxBytes: options.MaxBytes / int64(shardCount),
+			entries:  make(map[string]*entry),
+			policy:   policy,
+		}
+	}
+	return cache, nil
+}
+
+func (cache *Cache) shardFor(orderUid string) *shard {
+	if len(cache.shards) == 1 {
+		return cache.shards[0]
+	}
+	// Inlined FNV-1a, avoids allocating a hash.Hash32 on every lookup.
+	hash := uint32(2166136261)
+	for i := 0; i < len(orderUid); i++ {
+		hash ^= uint32(orderUid[i])
+		hash *= 16777619
+	}
+	return cache.shards[hash%uint32(len(cache.shards))]
 }
 
+// Add caches the order with the default TTL of the cache.
 func (cache *Cache) Add(order *models.Order) {
+	cache.AddWithTTL(order, cache.ttl)
+}
+
+// AddWithTTL caches the order for ttl, or without expiry if ttl is zero.
+func (cache *Cache) AddWithTTL(order *models.Order, ttl time.Duration) {
+	newEntry := &entry{order: order, size: EstimateSize(order)}
+	if ttl > 0 {
+		newEntry.expiresAt = cache.now().Add(ttl)
+	}
 
-	if existingElement, exist := cache.cacheMap[order.OrderUID]; exist {
-		existingElement.Value = order
-		cache.cacheList.MoveToFront(existingElement)
+	shard := cache.shardFor(order.OrderUID)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if shard.maxBytes > 0 && newEntry.size > shard.maxBytes {
+		log.Printf("Order %v is too large for the cache: %d bytes", order.OrderUID, newEntry.size)
+		shard.remove(order.OrderUID)
 		return
 	}
 
-	element := cache.cacheList.PushFront(order)
-	cache.cacheMap[order.OrderUID] = element
-	log.Printf("Add order into cache: %v", order.OrderUID)
+	if existing, exist := shard.entries[order.OrderUID]; exist {
+		shard.bytes += newEntry.size - existing.size
+		shard.entries[order.OrderUID] = newEntry
+		shard.policy.Access(order.OrderUID)
+	} else {
+		shard.entries[order.OrderUID] = newEntry
+		shard.bytes += newEntry.size
+		shard.policy.Add(order.OrderUID)
+		log.Printf("Add order into cache: %v", order.OrderUID)
+	}
 
-	if len(cache.cacheMap) > cache.capacity {
+	for shard.overflows() {
 		log.Printf("Remove oldest orders")
-		cache.removeOldest()
+		if !shard.evict() {
+			break
+		}
+		cache.evictions.Add(1)
 	}
 }
 
 func (cache *Cache) Get(orderUid string) (order *models.Order, exist bool, err error) {
-	element, exist := cache.cacheMap[orderUid]
+	shard := cache.shardFor(orderUid)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	cached, exist := shard.entries[orderUid]
 	if !exist {
-		return nil, false, err
+		cache.misses.Add(1)
+		return nil, false, nil
+	}
+
+	if cached.expired(cache.now()) {
+		shard.remove(orderUid)
+		cache.expirations.Add(1)
+		cache.misses.Add(1)
+		return nil, false, nil
 	}
 
-	cache.cacheList.MoveToFront(element)
-	return element.Value.(*models.Order), true, nil
+	shard.policy.Access(orderUid)
+	cache.hits.Add(1)
+	return cached.order, true, nil
 }
 
-func (cache *Cache) removeOldest() {
+// Contains reports whether a live order is cached without counting a hit or
+// changing its eviction order.
+func (cache *Cache) Contains(orderUid string) bool {
+	shard := cache.shardFor(orderUid)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	cached, exist := shard.entries[orderUid]
+	return exist && !cached.expired(cache.now())
+}
 
-	oldestElement := cache.cacheList.Back()
-	if oldestElement != nil {
-		delete(cache.cacheMap, oldestElement.Value.(*models.Order).OrderUID)
-		cache.cacheList.Remove(oldestElement)
+// Orders returns the live cached orders in no particular order, without
+// counting hits or changing their eviction order.
+func (cache *Cache) Orders() []*models.Order {
+	now := cache.now()
+	var orders []*models.Order
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		for _, cached := range shard.entries {
+			if !cached.expired(now) {
+				orders = append(orders, cached.order)
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return orders
+}
+
+// Remove drops the order from the cache and reports whether it was cached.
+func (cache *Cache) Remove(orderUid string) bool {
+	shard := cache.shardFor(orderUid)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	return shard.remove(orderUid)
+}
+
+// Flush drops every cached order. Counters are kept.
+func (cache *Cache) Flush() {
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		shard.entries = make(map[string]*entry)
+		shard.policy, _ = NewPolicy(cache.policy, shard.capacity)
+		shard.bytes = 0
+		shard.mu.Unlock()
+	}
+}
+
+// PurgeExpired drops every expired order and returns how many were dropped.
+func (cache *Cache) PurgeExpired() int {
+	now := cache.now()
+	purged := 0
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		for orderUid, cached := range shard.entries {
+			if cached.expired(now) {
+				shard.remove(orderUid)
+				purged++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	cache.expirations.Add(int64(purged))
+	return purged
+}
+
+func (cache *Cache) Stats() Stats {
+	stats := Stats{
+		Policy:      cache.policy,
+		Hits:        cache.hits.Load(),
+		Misses:      cache.misses.Load(),
+		Evictions:   cache.evictions.Load(),
+		Expirations: cache.expirations.Load(),
+		Size:        cache.Len(),
+		Capacity:    cache.capacity,
+		Bytes:       cache.Bytes(),
+		MaxBytes:    cache.maxBytes,
+	}
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
+}
+
+// Len returns the number of cached orders.
+func (cache *Cache) Len() int {
+	length := 0
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		length += len(shard.entries)
+		shard.mu.Unlock()
+	}
+	return length
+}
+
+// Bytes returns the estimated memory held by cached orders.
+func (cache *Cache) Bytes() int64 {
+	var bytes int64
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		bytes += shard.bytes
+		shard.mu.Unlock()
+	}
+	return bytes
+}
+
+func (cached *entry) expired(now time.Time) bool {
+	return !cached.expiresAt.IsZero() && !now.Before(cached.expiresAt)
+}
+
+func (shard *shard) overflows() bool {
+	if len(shard.entries) > shard.capacity {
+		return true
+	}
+	return shard.maxBytes > 0 && shard.bytes > shard.maxBytes
+}
+
+// evict drops the victim chosen by the policy.
+func (shard *shard) evict() bool {
+	orderUid, ok := shard.policy.Evict()
+	if !ok {
+		return false
+	}
+	if evicted, exist := shard.entries[orderUid]; exist {
+		delete(shard.entries, orderUid)
+		shard.bytes -= evicted.size
+	}
+	return true
+}
+
+func (shard *shard) remove(orderUid string) bool {
+	removed, exist := shard.entries[orderUid]
+	if !exist {
+		return false
 	}
+	delete(shard.entries, orderUid)
+	shard.policy.Remove(orderUid)
+	shard.bytes -= removed.size
+	return true
 }
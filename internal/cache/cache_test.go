@@ -1,56 +1,84 @@
 package cache
 
 import (
-	"test-task/pkg/models"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
 	"testing"
-	"math/rand"
+	"time"
+
+	"test-task/pkg/models"
 )
 
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func mustCreateCache(t *testing.T, options Options) *Cache {
+	t.Helper()
+	cache, err := CreateCacheWithOptions(options)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	return cache
+}
+
+func newOrder(orderUid string) *models.Order {
+	return &models.Order{
+		OrderUID:    orderUid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+	}
+}
+
 func TestCache_BaseFunctionality(t *testing.T) {
-	cache := MakeCache(5)
+	cache := CreateCache(5)
 
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-	saved_order := models.createRandomOrder(rng)
-	cache.Add(saved_order)
+	savedOrder := newOrder("order-1")
+	cache.Add(savedOrder)
 
-	from_cache, found := cache.Get(saved_order.OrderUID)
+	fromCache, found, err := cache.Get(savedOrder.OrderUID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
 	if !found {
-		t.Errorf("Cache didn't find added order")
+		t.Fatalf("Cache didn't find added order")
 	}
-	if from_cache != saved_order {
-		t.Errorf("Got different order. Got: %s, wanted: %s", from_cache.OrderUID, saved_order.Entry)
+	if fromCache != savedOrder {
+		t.Errorf("Got different order. Got: %s, wanted: %s", fromCache.OrderUID, savedOrder.OrderUID)
 	}
 }
 
 func TestCache_SearchInEmptyCache(t *testing.T) {
 	cache := CreateCache(2)
-	not_existing_id := "13s"
-	order, found := cache.Get(not_existing_id)
+	notExistingId := "13s"
+	order, found, _ := cache.Get(notExistingId)
 	if found {
-		t.Errorf("Found order in empty cache. got %s, searched for %s", order.OrderUID, not_existing_id)
+		t.Errorf("Found order in empty cache. got %s, searched for %s", order.OrderUID, notExistingId)
 	}
 }
 
 func TestCache_CacheEviction(t *testing.T) {
 	cache := CreateCache(2)
 
-	order1 := models.MakeRandomOrder()
-	order2 := models.MakeRandomOrder()
-	order3 := models.MakeRandomOrder()
+	order1 := newOrder("order-1")
+	order2 := newOrder("order-2")
+	order3 := newOrder("order-3")
 
 	cache.Add(order1)
 	cache.Add(order2)
-	cache.Add(order3) 
+	cache.Add(order3)
 
-	_, found := cache.Get(order1.OrderUID)
-	if found {
+	if _, found, _ := cache.Get(order1.OrderUID); found {
 		t.Error("Order1 should be evicted")
 	}
-
-	if _, found := cache.Get(order2.OrderUID); !found {
+	if _, found, _ := cache.Get(order2.OrderUID); !found {
 		t.Error("Order2 should still be in cache")
 	}
-	if _, found := cache.Get(order3.OrderUID); !found {
+	if _, found, _ := cache.Get(order3.OrderUID); !found {
 		t.Error("Order3 should still be in cache")
 	}
 }
@@ -58,25 +86,337 @@ func TestCache_CacheEviction(t *testing.T) {
 func TestCache_LRUOrderCheck(t *testing.T) {
 	cache := CreateCache(2)
 
-	order1 := models.MakeRandomOrder()
-	order2 := models.MakeRandomOrder()
-	order3 := models.MakeRandomOrder()
+	order1 := newOrder("order-1")
+	order2 := newOrder("order-2")
+	order3 := newOrder("order-3")
 
 	cache.Add(order1)
 	cache.Add(order2)
 
 	cache.Get(order1.OrderUID)
 
-	cache.Add(order3) 
+	cache.Add(order3)
 
-	if _, found := cache.Get(order2.OrderUID); found {
+	if _, found, _ := cache.Get(order2.OrderUID); found {
 		t.Error("Order2 should be evicted")
 	}
-
-	if _, found := cache.Get(order1.OrderUID); !found {
+	if _, found, _ := cache.Get(order1.OrderUID); !found {
 		t.Error("Order1 should still be in cache")
 	}
-	if _, found := cache.Get(order3.OrderUID); !found {
+	if _, found, _ := cache.Get(order3.OrderUID); !found {
+		t.Error("Order3 should still be in cache")
+	}
+}
+
+func TestCache_ShardedCapacity(t *testing.T) {
+	const capacity = maxShards * minShardCapacity
+	cache := CreateCache(capacity)
+	if len(cache.shards) != maxShards {
+		t.Fatalf("Expected %d shards, got %d", maxShards, len(cache.shards))
+	}
+
+	for i := 0; i < 5*capacity; i++ {
+		cache.Add(newOrder(fmt.Sprintf("order-%d", i)))
+	}
+	if length := cache.Len(); length > capacity {
+		t.Errorf("Cache holds %d orders, capacity is %d", length, capacity)
+	}
+}
+
+// Run with -race to check that concurrent readers and writers are synchronized.
+func TestCache_ConcurrentAccess(t *testing.T) {
+	const (
+		goroutines = 32
+		operations = 1000
+		keys       = 200
+	)
+	cache := CreateCache(keys / 2)
+
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < operations; i++ {
+				orderUid := fmt.Sprintf("order-%d", (g*operations+i)%keys)
+				if i%4 == 0 {
+					cache.Add(newOrder(orderUid))
+					continue
+				}
+				if order, found, _ := cache.Get(orderUid); found && order.OrderUID != orderUid {
+					t.Errorf("Got order %s for key %s", order.OrderUID, orderUid)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if length := cache.Len(); length > keys/2 {
+		t.Errorf("Cache holds %d orders, capacity is %d", length, keys/2)
+	}
+}
+
+func fillCache(b *testing.B, capacity int) (*Cache, []string) {
+	b.Helper()
+	cache := CreateCache(capacity)
+	keys := make([]string, capacity)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("order-%d", i)
+		cache.Add(newOrder(keys[i]))
+	}
+	return cache, keys
+}
+
+func BenchmarkCache_ParallelGet(b *testing.B) {
+	cache, keys := fillCache(b, 1024)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_ParallelMixed(b *testing.B) {
+	cache, keys := fillCache(b, 1024)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				cache.Add(newOrder(keys[i%len(keys)]))
+			} else {
+				cache.Get(keys[i%len(keys)])
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkCache_SingleShardParallelGet(b *testing.B) {
+	cache, keys := fillCache(b, minShardCapacity)
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			cache.Get(keys[i%len(keys)])
+			i++
+		}
+	})
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	return clock.now
+}
+
+func newOrderWithItems(orderUid string, items int) *models.Order {
+	order := newOrder(orderUid)
+	for i := 0; i < items; i++ {
+		order.Items = append(order.Items, models.Item{
+			TrackNumber: "WBILMTESTTRACK",
+			Name:        "Mascaras",
+			Brand:       "Vivienne Sabo",
+		})
+	}
+	return order
+}
+
+func TestCache_TTLExpiry(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 10, TTL: time.Minute})
+	cache.now = clock.Now
+
+	cache.Add(newOrder("order-1"))
+	cache.AddWithTTL(newOrder("order-2"), time.Hour)
+	cache.AddWithTTL(newOrder("order-3"), 0)
+
+	clock.now = clock.now.Add(2 * time.Minute)
+
+	if _, found, _ := cache.Get("order-1"); found {
+		t.Error("Order1 should be expired")
+	}
+	if _, found, _ := cache.Get("order-2"); !found {
+		t.Error("Order2 has its own TTL and should still be in cache")
+	}
+	if _, found, _ := cache.Get("order-3"); !found {
+		t.Error("Order3 has no TTL and should still be in cache")
+	}
+	if length := cache.Len(); length != 2 {
+		t.Errorf("Expired order should be removed, cache holds %d orders", length)
+	}
+}
+
+func TestCache_PurgeExpired(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 10, TTL: time.Minute})
+	cache.now = clock.Now
+
+	cache.Add(newOrder("order-1"))
+	cache.Add(newOrder("order-2"))
+	clock.now = clock.now.Add(30 * time.Second)
+	cache.Add(newOrder("order-3"))
+	clock.now = clock.now.Add(45 * time.Second)
+
+	if purged := cache.PurgeExpired(); purged != 2 {
+		t.Errorf("Expected 2 purged orders, got %d", purged)
+	}
+	if _, found, _ := cache.Get("order-3"); !found {
 		t.Error("Order3 should still be in cache")
 	}
 }
+
+func TestCache_ByteBudgetEviction(t *testing.T) {
+	small := EstimateSize(newOrderWithItems("order-1", 1))
+	large := EstimateSize(newOrderWithItems("order-3", 10))
+	budget := large + small
+	cache := mustCreateCache(t, Options{Capacity: 10, MaxBytes: budget})
+
+	cache.Add(newOrderWithItems("order-1", 1))
+	cache.Add(newOrderWithItems("order-2", 1))
+	if length := cache.Len(); length != 2 {
+		t.Fatalf("Expected 2 orders within budget, got %d", length)
+	}
+
+	cache.Add(newOrderWithItems("order-3", 10))
+
+	if bytes := cache.Bytes(); bytes > budget {
+		t.Errorf("Cache holds %d bytes, budget is %d", bytes, budget)
+	}
+	if _, found, _ := cache.Get("order-1"); found {
+		t.Error("Order1 should be evicted by the large order")
+	}
+	if _, found, _ := cache.Get("order-2"); !found {
+		t.Error("Order2 should still be in cache")
+	}
+	if _, found, _ := cache.Get("order-3"); !found {
+		t.Error("Order3 should be in cache")
+	}
+}
+
+func TestCache_OrderLargerThanBudget(t *testing.T) {
+	cache := mustCreateCache(t, Options{Capacity: 10, MaxBytes: 1024})
+
+	cache.Add(newOrderWithItems("order-1", 100))
+	if _, found, _ := cache.Get("order-1"); found {
+		t.Error("Order larger than the budget should not be cached")
+	}
+	if bytes := cache.Bytes(); bytes != 0 {
+		t.Errorf("Expected empty cache, got %d bytes", bytes)
+	}
+}
+
+func TestCache_ReplaceUpdatesBytes(t *testing.T) {
+	cache := CreateCache(10)
+
+	cache.Add(newOrderWithItems("order-1", 10))
+	cache.Add(newOrderWithItems("order-1", 1))
+
+	if bytes, want := cache.Bytes(), EstimateSize(newOrderWithItems("order-1", 1)); bytes != want {
+		t.Errorf("Expected %d bytes after replace, got %d", want, bytes)
+	}
+}
+
+func TestEstimateSize_GrowsWithItems(t *testing.T) {
+	if EstimateSize(newOrderWithItems("order-1", 10)) <= EstimateSize(newOrderWithItems("order-1", 1)) {
+		t.Error("Order with more items should be estimated larger")
+	}
+}
+
+func TestCache_Stats(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 2, TTL: time.Minute})
+	cache.now = clock.Now
+
+	cache.Add(newOrder("order-1"))
+	cache.Add(newOrder("order-2"))
+	cache.Get("order-1")
+	cache.Get("order-1")
+	cache.Get("missing")
+	cache.Add(newOrder("order-3"))
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	cache.Get("order-3")
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Expected 2 hits and 2 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+	if stats.HitRatio != 0.5 {
+		t.Errorf("Expected hit ratio 0.5, got %v", stats.HitRatio)
+	}
+	if stats.Evictions != 1 || stats.Expirations != 1 {
+		t.Errorf("Expected 1 eviction and 1 expiration, got %d and %d", stats.Evictions, stats.Expirations)
+	}
+	if stats.Size != 1 || stats.Capacity != 2 {
+		t.Errorf("Expected size 1 of capacity 2, got %d of %d", stats.Size, stats.Capacity)
+	}
+}
+
+func TestCache_ContainsDoesNotPromote(t *testing.T) {
+	cache := CreateCache(2)
+
+	cache.Add(newOrder("order-1"))
+	cache.Add(newOrder("order-2"))
+	if !cache.Contains("order-1") {
+		t.Fatal("Order1 should be cached")
+	}
+	cache.Add(newOrder("order-3"))
+
+	if cache.Contains("order-1") {
+		t.Error("Contains should not protect order1 from eviction")
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Contains should not count lookups, got %+v", stats)
+	}
+}
+
+func TestCache_RemoveAndFlush(t *testing.T) {
+	cache := CreateCache(10)
+
+	cache.Add(newOrder("order-1"))
+	cache.Add(newOrder("order-2"))
+
+	if !cache.Remove("order-1") {
+		t.Error("Remove should report cached order1")
+	}
+	if cache.Remove("order-1") {
+		t.Error("Remove should report order1 is already gone")
+	}
+
+	cache.Flush()
+	if length, bytes := cache.Len(), cache.Bytes(); length != 0 || bytes != 0 {
+		t.Errorf("Expected empty cache after flush, got %d orders and %d bytes", length, bytes)
+	}
+	cache.Add(newOrder("order-3"))
+	if !cache.Contains("order-3") {
+		t.Error("Cache should accept orders after flush")
+	}
+}
+
+func TestCache_OrdersSkipsExpired(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 10})
+	cache.now = clock.Now
+
+	cache.Add(newOrder("order-1"))
+	cache.AddWithTTL(newOrder("order-2"), time.Second)
+	clock.now = clock.now.Add(time.Second)
+
+	orders := cache.Orders()
+	if len(orders) != 1 || orders[0].OrderUID != "order-1" {
+		t.Errorf("Expected only the live order1, got %d orders", len(orders))
+	}
+	if stats := cache.Stats(); stats.Hits != 0 || stats.Misses != 0 {
+		t.Errorf("Orders should not count lookups, got %+v", stats)
+	}
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"time"
+)
+
+// NegativeCache remembers for a short time which order uids are known to be
+// missing, so repeated lookups of unknown uids do not reach the database.
+// When full, the oldest entry is dropped.
+type NegativeCache struct {
+	ttlLRU[struct{}]
+}
+
+func CreateNegativeCache(capacity int, ttl time.Duration) *NegativeCache {
+	return &NegativeCache{ttlLRU: newTTLLRU[struct{}](capacity, ttl)}
+}
+
+// Add marks orderUid as missing for the TTL of the cache.
+func (negative *NegativeCache) Add(orderUid string) {
+	negative.add(orderUid, struct{}{})
+}
+
+// Contains reports whether orderUid is known to be missing.
+func (negative *NegativeCache) Contains(orderUid string) bool {
+	_, found := negative.get(orderUid, false)
+	return found
+}
+
+// Remove forgets orderUid, e.g. once the order is stored.
+func (negative *NegativeCache) Remove(orderUid string) {
+	negative.remove(orderUid)
+}
+
+func (negative *NegativeCache) Flush() {
+	negative.flush()
+}
+
+func (negative *NegativeCache) Len() int {
+	return negative.len()
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNegativeCache_Expiry(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	negative := CreateNegativeCache(10, time.Second)
+	negative.now = clock.Now
+
+	negative.Add("missing")
+	if !negative.Contains("missing") {
+		t.Fatal("Missing uid should be remembered")
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	if negative.Contains("missing") {
+		t.Error("Missing uid should expire after TTL")
+	}
+	if length := negative.Len(); length != 0 {
+		t.Errorf("Expired uid should be removed, got %d entries", length)
+	}
+}
+
+func TestNegativeCache_Remove(t *testing.T) {
+	negative := CreateNegativeCache(10, time.Minute)
+
+	negative.Add("order-1")
+	negative.Remove("order-1")
+	if negative.Contains("order-1") {
+		t.Error("Removed uid should not be reported missing")
+	}
+}
+
+func TestNegativeCache_Capacity(t *testing.T) {
+	negative := CreateNegativeCache(2, time.Minute)
+
+	negative.Add("order-1")
+	negative.Add("order-2")
+	negative.Add("order-3")
+
+	if negative.Contains("order-1") {
+		t.Error("Oldest uid should be dropped")
+	}
+	if !negative.Contains("order-2") || !negative.Contains("order-3") {
+		t.Error("Newest uids should be kept")
+	}
+}
+
+func TestNegativeCache_ConcurrentAccess(t *testing.T) {
+	negative := CreateNegativeCache(50, time.Minute)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				orderUid := fmt.Sprintf("order-%d", (g+i)%100)
+				switch i % 3 {
+				case 0:
+					negative.Add(orderUid)
+				case 1:
+					negative.Contains(orderUid)
+				default:
+					negative.Remove(orderUid)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if length := negative.Len(); length > 50 {
+		t.Errorf("Negative cache holds %d uids, capacity is 50", length)
+	}
+}
@@ -0,0 +1,260 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Policy decides which order is evicted when a shard is full. A policy only
+// tracks keys, the shard keeps the orders. Policies are not safe for
+// concurrent use; the shard calls them under its lock.
+type Policy interface {
+	// Add records a key that has just been cached.
+	Add(key string)
+	// Access records a hit on a cached key.
+	Access(key string)
+	// Remove forgets a key that was deleted or expired.
+	Remove(key string)
+	// Evict picks a victim, forgets it and returns it.
+	Evict() (key string, ok bool)
+	// Keys returns the cached keys from the most to the least valuable.
+	Keys() []string
+}
+
+type PolicyName string
+
+const (
+	PolicyLRU PolicyName = "lru"
+	PolicyLFU PolicyName = "lfu"
+	Policy2Q  PolicyName = "2q"
+)
+
+// NewPolicy creates an empty policy for a shard holding up to capacity keys.
+func NewPolicy(name PolicyName, capacity int) (Policy, error) {
+	switch name {
+	case PolicyLRU, "":
+		return newLRU(), nil
+	case PolicyLFU:
+		return newLFU(), nil
+	case Policy2Q:
+		return newTwoQueue(capacity), nil
+	}
+	return nil, fmt.Errorf("unknown cache policy %q", name)
+}
+
+// lru evicts the least recently used key.
+type lru struct {
+	keys  map[string]*list.Element
+	order *list.List
+}
+
+func newLRU() *lru {
+	return &lru{
+		keys:  make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+func (policy *lru) Add(key string) {
+	if element, exist := policy.keys[key]; exist {
+		policy.order.MoveToFront(element)
+		return
+	}
+	policy.keys[key] = policy.order.PushFront(key)
+}
+
+func (policy *lru) Access(key string) {
+	if element, exist := policy.keys[key]; exist {
+		policy.order.MoveToFront(element)
+	}
+}
+
+func (policy *lru) Remove(key string) {
+	if element, exist := policy.keys[key]; exist {
+		delete(policy.keys, key)
+		policy.order.Remove(element)
+	}
+}
+
+func (policy *lru) Evict() (string, bool) {
+	oldest := policy.order.Back()
+	if oldest == nil {
+		return "", false
+	}
+	key := oldest.Value.(string)
+	policy.Remove(key)
+	return key, true
+}
+
+func (policy *lru) Keys() []string {
+	keys := make([]string, 0, len(policy.keys))
+	for element := policy.order.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(string))
+	}
+	return keys
+}
+
+// lfu evicts the least frequently used key, the least recently used one
+// among keys with the same frequency. All operations are O(1) except
+// finding the next lowest frequency after the lowest bucket empties.
+type lfu struct {
+	keys    map[string]*lfuNode
+	buckets map[int]*list.List
+	minFreq int
+}
+
+type lfuNode struct {
+	freq    int
+	element *list.Element
+}
+
+func newLFU() *lfu {
+	return &lfu{
+		keys:    make(map[string]*lfuNode),
+		buckets: make(map[int]*list.List),
+	}
+}
+
+func (policy *lfu) Add(key string) {
+	if _, exist := policy.keys[key]; exist {
+		policy.Access(key)
+		return
+	}
+	policy.keys[key] = &lfuNode{freq: 1, element: policy.bucket(1).PushFront(key)}
+	policy.minFreq = 1
+}
+
+func (policy *lfu) Access(key string) {
+	node, exist := policy.keys[key]
+	if !exist {
+		return
+	}
+	policy.unlink(node)
+	node.freq++
+	node.element = policy.bucket(node.freq).PushFront(key)
+}
+
+func (policy *lfu) Remove(key string) {
+	if node, exist := policy.keys[key]; exist {
+		policy.unlink(node)
+		delete(policy.keys, key)
+	}
+}
+
+func (policy *lfu) Evict() (string, bool) {
+	if len(policy.keys) == 0 {
+		return "", false
+	}
+	if _, exist := policy.buckets[policy.minFreq]; !exist {
+		policy.minFreq = 0
+		for freq := range policy.buckets {
+			if policy.minFreq == 0 || freq < policy.minFreq {
+				policy.minFreq = freq
+			}
+		}
+	}
+	key := policy.buckets[policy.minFreq].Back().Value.(string)
+	policy.Remove(key)
+	return key, true
+}
+
+func (policy *lfu) Keys() []string {
+	maxFreq := 0
+	for freq := range policy.buckets {
+		maxFreq = max(maxFreq, freq)
+	}
+	keys := make([]string, 0, len(policy.keys))
+	for freq := maxFreq; freq > 0 && len(keys) < len(policy.keys); freq-- {
+		bucket, exist := policy.buckets[freq]
+		if !exist {
+			continue
+		}
+		for element := bucket.Front(); element != nil; element = element.Next() {
+			keys = append(keys, element.Value.(string))
+		}
+	}
+	return keys
+}
+
+func (policy *lfu) bucket(freq int) *list.List {
+	bucket, exist := policy.buckets[freq]
+	if !exist {
+		bucket = list.New()
+		policy.buckets[freq] = bucket
+	}
+	return bucket
+}
+
+func (policy *lfu) unlink(node *lfuNode) {
+	bucket := policy.buckets[node.freq]
+	bucket.Remove(node.element)
+	if bucket.Len() == 0 {
+		delete(policy.buckets, node.freq)
+	}
+}
+
+// twoQueue is the full 2Q algorithm. New keys enter the FIFO queue in; only
+// keys requested again after falling out of it, while still remembered in
+// the ghost queue out, are promoted to the LRU queue main. One-off lookups
+// therefore never push hot keys out of main.
+type twoQueue struct {
+	inCapacity  int
+	outCapacity int
+
+	in   *lru
+	out  *lru
+	main *lru
+}
+
+func newTwoQueue(capacity int) *twoQueue {
+	return &twoQueue{
+		inCapacity:  max(capacity/4, 1),
+		outCapacity: max(capacity/2, 1),
+		in:          newLRU(),
+		out:         newLRU(),
+		main:        newLRU(),
+	}
+}
+
+func (policy *twoQueue) Add(key string) {
+	if _, exist := policy.main.keys[key]; exist {
+		policy.main.Access(key)
+		return
+	}
+	if _, exist := policy.in.keys[key]; exist {
+		return
+	}
+	if _, exist := policy.out.keys[key]; exist {
+		policy.out.Remove(key)
+		policy.main.Add(key)
+		return
+	}
+	policy.in.Add(key)
+}
+
+func (policy *twoQueue) Access(key string) {
+	// Hits in the in queue do not change its FIFO order.
+	policy.main.Access(key)
+}
+
+func (policy *twoQueue) Remove(key string) {
+	policy.in.Remove(key)
+	policy.main.Remove(key)
+}
+
+func (policy *twoQueue) Evict() (string, bool) {
+	if len(policy.in.keys) > policy.inCapacity || len(policy.main.keys) == 0 {
+		if key, ok := policy.in.Evict(); ok {
+			policy.out.Add(key)
+			if len(policy.out.keys) > policy.outCapacity {
+				policy.out.Evict()
+			}
+			return key, true
+		}
+	}
+	return policy.main.Evict()
+}
+
+func (policy *twoQueue) Keys() []string {
+	return append(policy.main.Keys(), policy.in.Keys()...)
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+var policies = []PolicyName{PolicyLRU, PolicyLFU, Policy2Q}
+
+func TestNewPolicy_Unknown(t *testing.T) {
+	if _, err := NewPolicy("fifo", 10); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+	if _, err := CreateCacheWithOptions(Options{Capacity: 10, Policy: "fifo"}); err == nil {
+		t.Error("Expected error for cache with unknown policy")
+	}
+}
+
+func TestPolicies_RespectCapacity(t *testing.T) {
+	for _, name := range policies {
+		t.Run(string(name), func(t *testing.T) {
+			cache := mustCreateCache(t, Options{Capacity: 10, Policy: name})
+			for i := 0; i < 100; i++ {
+				cache.Add(newOrder(fmt.Sprintf("order-%d", i%30)))
+				cache.Get(fmt.Sprintf("order-%d", i%7))
+			}
+			if length := cache.Len(); length != 10 {
+				t.Errorf("Expected 10 cached orders, got %d", length)
+			}
+			if keys := cache.shards[0].policy.Keys(); len(keys) != 10 {
+				t.Errorf("Policy tracks %d keys, cache holds 10", len(keys))
+			}
+		})
+	}
+}
+
+func TestPolicies_RemoveAndFlush(t *testing.T) {
+	for _, name := range policies {
+		t.Run(string(name), func(t *testing.T) {
+			cache := mustCreateCache(t, Options{Capacity: 4, Policy: name})
+			for i := 0; i < 4; i++ {
+				cache.Add(newOrder(fmt.Sprintf("order-%d", i)))
+			}
+			cache.Remove("order-0")
+			cache.Add(newOrder("order-4"))
+			if length := cache.Len(); length != 4 {
+				t.Errorf("Removed order should free a slot, got %d orders", length)
+			}
+
+			cache.Flush()
+			if keys := cache.shards[0].policy.Keys(); len(keys) != 0 {
+				t.Errorf("Flush should reset the policy, it tracks %v", keys)
+			}
+		})
+	}
+}
+
+func TestLFU_KeepsFrequentOrders(t *testing.T) {
+	cache := mustCreateCache(t, Options{Capacity: 2, Policy: PolicyLFU})
+
+	cache.Add(newOrder("hot"))
+	cache.Get("hot")
+	cache.Get("hot")
+	cache.Add(newOrder("cold-1"))
+	cache.Add(newOrder("cold-2"))
+
+	if !cache.Contains("hot") {
+		t.Error("Frequently used order should stay cached")
+	}
+	if cache.Contains("cold-1") {
+		t.Error("Least frequently used order should be evicted")
+	}
+}
+
+func TestTwoQueue_ScanDoesNotFlushHotOrders(t *testing.T) {
+	cache := mustCreateCache(t, Options{Capacity: 8, Policy: Policy2Q})
+
+	// Make hot orders pass through the in queue and come back from the ghost
+	// queue, which promotes them to the main queue.
+	for round := 0; round < 2; round++ {
+		for i := 0; i < 4; i++ {
+			orderUid := fmt.Sprintf("hot-%d", i)
+			if _, found, _ := cache.Get(orderUid); !found {
+				cache.Add(newOrder(orderUid))
+			}
+		}
+		for i := 0; i < 8; i++ {
+			cache.Add(newOrder(fmt.Sprintf("warmup-%d-%d", round, i)))
+		}
+	}
+	for i := 0; i < 4; i++ {
+		orderUid := fmt.Sprintf("hot-%d", i)
+		if _, found, _ := cache.Get(orderUid); !found {
+			cache.Add(newOrder(orderUid))
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.Add(newOrder(fmt.Sprintf("scan-%d", i)))
+	}
+
+	for i := 0; i < 4; i++ {
+		if !cache.Contains(fmt.Sprintf("hot-%d", i)) {
+			t.Errorf("hot-%d should survive a scan of one-off orders", i)
+		}
+	}
+}
+
+// accessTrace returns the trace the policy benchmarks replay. CACHE_TRACE may
+// point to a file with one order_uid per line recorded from production logs;
+// otherwise a synthetic trace with the same shape is generated: a Zipf
+// distributed hot set interleaved with a long tail of one-off lookups.
+func accessTrace(b *testing.B) []string {
+	b.Helper()
+	if path := os.Getenv("CACHE_TRACE"); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			b.Fatalf("Failed to open trace: %v", err)
+		}
+		defer file.Close()
+
+		var trace []string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if key := strings.TrimSpace(scanner.Text()); key != "" {
+				trace = append(trace, key)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			b.Fatalf("Failed to read trace: %v", err)
+		}
+		return trace
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	zipf := rand.NewZipf(rng, 1.1, 1, 4999)
+	trace := make([]string, 0, 200000)
+	oneOff := 0
+	for len(trace) < cap(trace) {
+		if rng.Intn(100) < 40 {
+			trace = append(trace, fmt.Sprintf("tail-%d", oneOff))
+			oneOff++
+			continue
+		}
+		trace = append(trace, fmt.Sprintf("hot-%d", zipf.Uint64()))
+	}
+	return trace
+}
+
+func BenchmarkPolicies_HitRatio(b *testing.B) {
+	trace := accessTrace(b)
+	for _, name := range policies {
+		b.Run(string(name), func(b *testing.B) {
+			var stats Stats
+			for i := 0; i < b.N; i++ {
+				cache, err := CreateCacheWithOptions(Options{Capacity: 1000, Policy: name})
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, orderUid := range trace {
+					if _, found, _ := cache.Get(orderUid); !found {
+						cache.Add(newOrder(orderUid))
+					}
+				}
+				stats = cache.Stats()
+			}
+			b.ReportMetric(stats.HitRatio, "hit_ratio")
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"unsafe"
+
+	"test-task/pkg/models"
+)
+
+// Rough per-entry overhead of the cache itself: the entry struct, the map
+// slot and the bookkeeping of the eviction policy.
+const entryOverhead = int64(unsafe.Sizeof(entry{})) + 48 + 64
+
+// EstimateSize approximates the memory an order takes in the cache: struct
+// sizes plus the bytes of every string it references.
+func EstimateSize(order *models.Order) int64 {
+	size := entryOverhead + int64(unsafe.Sizeof(*order))
+	size += int64(len(order.OrderUID) + len(order.TrackNumber) + len(order.Entry) +
+		len(order.Locale) + len(order.InternalSignature) + len(order.CustomerID) +
+		len(order.DeliveryService) + len(order.Shardkey) + len(order.OofShard))
+
+	delivery := &order.Delivery
+	size += int64(len(delivery.OrderUID) + len(delivery.Name) + len(delivery.Phone) +
+		len(delivery.Zip) + len(delivery.City) + len(delivery.Address) +
+		len(delivery.Region) + len(delivery.Email))
+
+	payment := &order.Payment
+	size += int64(len(payment.OrderUID) + len(payment.Transaction) + len(payment.RequestID) +
+		len(payment.Currency) + len(payment.Provider) + len(payment.Bank))
+
+	size += int64(cap(order.Items)) * int64(unsafe.Sizeof(models.Item{}))
+	for i := range order.Items {
+		item := &order.Items[i]
+		size += int64(len(item.OrderUID) + len(item.TrackNumber) + len(item.Rid) +
+			len(item.Name) + len(item.Size) + len(item.Brand))
+	}
+
+	return size
+}
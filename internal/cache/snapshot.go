@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"test-task/pkg/models"
+)
+
+// snapshotMagic starts every snapshot file; the last byte is the format version.
+var snapshotMagic = []byte("ORDCACH\x02")
+
+var (
+	ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+	ErrSnapshotStale   = errors.New("cache snapshot is too old")
+)
+
+type snapshot struct {
+	CreatedAt time.Time
+	// Entries go from the most to the least valuable, per shard.
+	Entries []snapshotEntry
+}
+
+type snapshotEntry struct {
+	Order     models.Order
+	ExpiresAt time.Time
+}
+
+// WriteSnapshot writes every live order to w in eviction order, followed by
+// a checksum so a truncated or damaged snapshot is detected on load.
+func (cache *Cache) WriteSnapshot(w io.Writer) error {
+	now := cache.now()
+	data := snapshot{CreatedAt: now}
+	for _, shard := range cache.shards {
+		shard.mu.Lock()
+		for _, orderUid := range shard.policy.Keys() {
+			cached, exist := shard.entries[orderUid]
+			if !exist || cached.expired(now) {
+				continue
+			}
+			data.Entries = append(data.Entries, snapshotEntry{
+				Order:     *cached.order,
+				ExpiresAt: cached.expiresAt,
+			})
+		}
+		shard.mu.Unlock()
+	}
+
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&data); err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	header := make([]byte, len(snapshotMagic)+4)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint32(header[len(snapshotMagic):], crc32.ChecksumIEEE(payload.Bytes()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// ReadSnapshot adds the orders of a snapshot to the cache, keeping their
+// relative eviction order, and returns how many were added. Snapshots older
+// than maxAge are rejected with ErrSnapshotStale, maxAge 0 accepts any age.
+func (cache *Cache) ReadSnapshot(r io.Reader, maxAge time.Duration) (int, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	headerSize := len(snapshotMagic) + 4
+	if len(raw) < headerSize || !bytes.Equal(raw[:len(snapshotMagic)], snapshotMagic) {
+		return 0, ErrSnapshotCorrupt
+	}
+	payload := raw[headerSize:]
+	if binary.BigEndian.Uint32(raw[len(snapshotMagic):headerSize]) != crc32.ChecksumIEEE(payload) {
+		return 0, ErrSnapshotCorrupt
+	}
+
+	var data snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&data); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrSnapshotCorrupt, err)
+	}
+
+	now := cache.now()
+	if maxAge > 0 && now.Sub(data.CreatedAt) > maxAge {
+		return 0, fmt.Errorf("%w: created at %v", ErrSnapshotStale, data.CreatedAt)
+	}
+
+	// Add the least valuable orders first, so the most valuable ones end up
+	// where the policy keeps them longest.
+	loaded := 0
+	for i := len(data.Entries) - 1; i >= 0; i-- {
+		restored := &data.Entries[i]
+		var ttl time.Duration
+		if !restored.ExpiresAt.IsZero() {
+			ttl = restored.ExpiresAt.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+		}
+		cache.AddWithTTL(&restored.Order, ttl)
+		loaded++
+	}
+	return loaded, nil
+}
+
+// SaveSnapshotFile writes a snapshot next to path and renames it into place,
+// so a crash while writing never leaves a partial snapshot behind.
+func (cache *Cache) SaveSnapshotFile(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create snapshot: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := cache.WriteSnapshot(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (cache *Cache) LoadSnapshotFile(path string, maxAge time.Duration) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return cache.ReadSnapshot(file, maxAge)
+}
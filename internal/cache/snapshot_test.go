@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshot_RoundTripKeepsLRUOrder(t *testing.T) {
+	cache := mustCreateCache(t, Options{Capacity: 3})
+	cache.Add(newOrderWithItems("order-1", 2))
+	cache.Add(newOrder("order-2"))
+	cache.Add(newOrder("order-3"))
+	cache.Get("order-1")
+
+	var buffer bytes.Buffer
+	if err := cache.WriteSnapshot(&buffer); err != nil {
+		t.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	restored := mustCreateCache(t, Options{Capacity: 3})
+	loaded, err := restored.ReadSnapshot(&buffer, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to read snapshot: %v", err)
+	}
+	if loaded != 3 {
+		t.Fatalf("Expected 3 restored orders, got %d", loaded)
+	}
+
+	order, found, _ := restored.Get("order-1")
+	if !found || len(order.Items) != 2 {
+		t.Fatalf("Order1 is not restored with its items: %+v", order)
+	}
+
+	// order-2 was the least recently used one before the snapshot.
+	restored.Add(newOrder("order-4"))
+	if restored.Contains("order-2") {
+		t.Error("Order2 should be evicted first after restore")
+	}
+	if !restored.Contains("order-3") {
+		t.Error("Order3 should still be cached")
+	}
+}
+
+func TestSnapshot_Stale(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 3})
+	cache.now = clock.Now
+	cache.Add(newOrder("order-1"))
+
+	var buffer bytes.Buffer
+	if err := cache.WriteSnapshot(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := mustCreateCache(t, Options{Capacity: 3})
+	restored.now = func() time.Time { return clock.now.Add(2 * time.Hour) }
+	if _, err := restored.ReadSnapshot(&buffer, time.Hour); !errors.Is(err, ErrSnapshotStale) {
+		t.Errorf("Expected stale snapshot error, got %v", err)
+	}
+	if restored.Len() != 0 {
+		t.Error("Stale snapshot should not be loaded")
+	}
+}
+
+func TestSnapshot_SkipsExpiredOrders(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cache := mustCreateCache(t, Options{Capacity: 3, TTL: time.Minute})
+	cache.now = clock.Now
+	cache.Add(newOrder("order-1"))
+	cache.AddWithTTL(newOrder("order-2"), time.Hour)
+
+	var buffer bytes.Buffer
+	if err := cache.WriteSnapshot(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := mustCreateCache(t, Options{Capacity: 3})
+	restored.now = func() time.Time { return clock.now.Add(5 * time.Minute) }
+	loaded, err := restored.ReadSnapshot(&buffer, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != 1 || !restored.Contains("order-2") {
+		t.Errorf("Expected only order2 to be restored, got %d orders", loaded)
+	}
+}
+
+func TestSnapshot_Corrupt(t *testing.T) {
+	cache := mustCreateCache(t, Options{Capacity: 3})
+	cache.Add(newOrder("order-1"))
+
+	var buffer bytes.Buffer
+	if err := cache.WriteSnapshot(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+
+	tests := map[string][]byte{
+		"empty":     {},
+		"magic":     append([]byte("NOTACACH"), data[8:]...),
+		"truncated": data[:len(data)-5],
+		"flipped":   append(append([]byte{}, data[:len(data)-1]...), data[len(data)-1]^0xff),
+	}
+	for name, corrupt := range tests {
+		t.Run(name, func(t *testing.T) {
+			restored := mustCreateCache(t, Options{Capacity: 3})
+			if _, err := restored.ReadSnapshot(bytes.NewReader(corrupt), 0); !errors.Is(err, ErrSnapshotCorrupt) {
+				t.Errorf("Expected corrupt snapshot error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSnapshot_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	cache := mustCreateCache(t, Options{Capacity: 100})
+	for i := 0; i < 50; i++ {
+		cache.Add(newOrder(fmt.Sprintf("order-%d", i)))
+	}
+	if err := cache.SaveSnapshotFile(path); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	restored := mustCreateCache(t, Options{Capacity: 100})
+	loaded, err := restored.LoadSnapshotFile(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to load snapshot: %v", err)
+	}
+	if loaded != 50 {
+		t.Errorf("Expected 50 restored orders, got %d", loaded)
+	}
+
+	missing := mustCreateCache(t, Options{Capacity: 100})
+	if _, err := missing.LoadSnapshotFile(path+".missing", time.Hour); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"time"
+)
+
+// TrackCache maps track numbers to the uids of the orders they belong to,
+// so lookups by track number skip the database. The orders themselves stay
+// in the Cache keyed by uid. When full, the least recently used track number
+// is dropped.
+type TrackCache struct {
+	ttlLRU[[]string]
+}
+
+func CreateTrackCache(capacity int, ttl time.Duration) *TrackCache {
+	return &TrackCache{ttlLRU: newTTLLRU[[]string](capacity, ttl)}
+}
+
+// Add remembers the uids of the orders with trackNumber, which may be none.
+func (tracks *TrackCache) Add(trackNumber string, orderUids []string) {
+	tracks.add(trackNumber, append([]string(nil), orderUids...))
+}
+
+// Get returns a copy of the uids remembered for trackNumber.
+func (tracks *TrackCache) Get(trackNumber string) ([]string, bool) {
+	orderUids, found := tracks.get(trackNumber, true)
+	if !found {
+		return nil, false
+	}
+	return append([]string(nil), orderUids...), true
+}
+
+// Remove forgets trackNumber, e.g. once an order with it is stored.
+func (tracks *TrackCache) Remove(trackNumber string) {
+	tracks.remove(trackNumber)
+}
+
+func (tracks *TrackCache) Flush() {
+	tracks.flush()
+}
+
+func (tracks *TrackCache) Len() int {
+	return tracks.len()
+}
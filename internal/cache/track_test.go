@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTrackCache_AddGet(t *testing.T) {
+	tracks := CreateTrackCache(10, time.Minute)
+
+	uids := []string{"order-1", "order-2"}
+	tracks.Add("WBILMTESTTRACK", uids)
+	uids[0] = "changed by caller"
+
+	got, found := tracks.Get("WBILMTESTTRACK")
+	if !found || fmt.Sprint(got) != "[order-1 order-2]" {
+		t.Errorf("Got %v, %v", got, found)
+	}
+
+	tracks.Add("UNKNOWN", nil)
+	if got, found := tracks.Get("UNKNOWN"); !found || len(got) != 0 {
+		t.Errorf("Track number without orders should be remembered, got %v, %v", got, found)
+	}
+}
+
+func TestTrackCache_Expiry(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	tracks := CreateTrackCache(10, time.Second)
+	tracks.now = clock.Now
+
+	tracks.Add("track", []string{"order-1"})
+	clock.now = clock.now.Add(time.Second)
+	if _, found := tracks.Get("track"); found {
+		t.Error("Track number should expire after TTL")
+	}
+	if length := tracks.Len(); length != 0 {
+		t.Errorf("Expired track number should be removed, got %d entries", length)
+	}
+}
+
+func TestTrackCache_CapacityDropsLeastRecentlyUsed(t *testing.T) {
+	tracks := CreateTrackCache(2, time.Minute)
+
+	tracks.Add("track-1", []string{"order-1"})
+	tracks.Add("track-2", []string{"order-2"})
+	tracks.Get("track-1")
+	tracks.Add("track-3", []string{"order-3"})
+
+	if _, found := tracks.Get("track-2"); found {
+		t.Error("Least recently used track number should be dropped")
+	}
+	if _, found := tracks.Get("track-1"); !found {
+		t.Error("Recently used track number should be kept")
+	}
+}
+
+func TestTrackCache_RemoveFlush(t *testing.T) {
+	tracks := CreateTrackCache(10, time.Minute)
+	tracks.Add("track-1", []string{"order-1"})
+	tracks.Add("track-2", []string{"order-2"})
+
+	tracks.Remove("track-1")
+	if _, found := tracks.Get("track-1"); found {
+		t.Error("Removed track number is still cached")
+	}
+	tracks.Flush()
+	if length := tracks.Len(); length != 0 {
+		t.Errorf("Flushed cache has %d entries", length)
+	}
+}
+
+func TestTrackCache_NonPositiveCapacity(t *testing.T) {
+	tracks := CreateTrackCache(-1, time.Minute)
+
+	tracks.Add("track", []string{"order-1"})
+	if length := tracks.Len(); length != 0 {
+		t.Errorf("Cache without capacity should keep nothing, got %d entries", length)
+	}
+}
@@ -0,0 +1,108 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ttlLRU is a bounded map of keys to values that expire after a fixed TTL.
+// Entries are added at the front of a list and, when it is full, dropped
+// from the back. It backs the small caches next to the order Cache.
+type ttlLRU[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+type ttlEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+func newTTLLRU[V any](capacity int, ttl time.Duration) ttlLRU[V] {
+	return ttlLRU[V]{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// add stores value for the TTL and moves key to the front.
+func (cache *ttlLRU[V]) add(key string, value V) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	entry := &ttlEntry[V]{key: key, value: value, expiresAt: cache.now().Add(cache.ttl)}
+	if element, exist := cache.entries[key]; exist {
+		element.Value = entry
+		cache.order.MoveToFront(element)
+		return
+	}
+
+	cache.entries[key] = cache.order.PushFront(entry)
+	for len(cache.entries) > cache.capacity {
+		back := cache.order.Back()
+		if back == nil {
+			break
+		}
+		cache.removeElement(back)
+	}
+}
+
+// get returns the value of an unexpired key. With touch the key is moved to
+// the front, so the list is ordered by use rather than by addition.
+func (cache *ttlLRU[V]) get(key string, touch bool) (V, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var zero V
+	element, exist := cache.entries[key]
+	if !exist {
+		return zero, false
+	}
+	entry := element.Value.(*ttlEntry[V])
+	if !cache.now().Before(entry.expiresAt) {
+		cache.removeElement(element)
+		return zero, false
+	}
+	if touch {
+		cache.order.MoveToFront(element)
+	}
+	return entry.value, true
+}
+
+func (cache *ttlLRU[V]) remove(key string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, exist := cache.entries[key]; exist {
+		cache.removeElement(element)
+	}
+}
+
+func (cache *ttlLRU[V]) flush() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.entries = make(map[string]*list.Element)
+	cache.order.Init()
+}
+
+func (cache *ttlLRU[V]) len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	return len(cache.entries)
+}
+
+func (cache *ttlLRU[V]) removeElement(element *list.Element) {
+	delete(cache.entries, element.Value.(*ttlEntry[V]).key)
+	cache.order.Remove(element)
+}
@@ -0,0 +1,278 @@
+package dlq
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Headers attached to every message forwarded to the dead-letter topic.
+const (
+	HeaderError           = "x-dlq-error"
+	HeaderSourceTopic     = "x-dlq-source-topic"
+	HeaderSourcePartition = "x-dlq-source-partition"
+	HeaderSourceOffset    = "x-dlq-source-offset"
+	HeaderFailedAt        = "x-dlq-failed-at"
+	HeaderRedrivenFrom    = "x-dlq-redriven-from"
+)
+
+const fetchTimeout = 5 * time.Second
+
+var ErrEntryNotFound = errors.New("dlq entry not found")
+
+type Entry struct {
+	Partition       int32     `json:"partition"`
+	Offset          int64     `json:"offset"`
+	Key             string    `json:"key,omitempty"`
+	Error           string    `json:"error"`
+	SourceTopic     string    `json:"source_topic"`
+	SourcePartition int32     `json:"source_partition"`
+	SourceOffset    int64     `json:"source_offset"`
+	FailedAt        time.Time `json:"failed_at"`
+	Payload         string    `json:"payload"`
+}
+
+// Queue forwards rejected messages to the dead-letter topic and moves them
+// back to the source topic on request.
+type Queue struct {
+	client      sarama.Client
+	producer    sarama.SyncProducer
+	topic       string
+	sourceTopic string
+	// newConsumer reads the dead-letter topic; tests replace it.
+	newConsumer func(client sarama.Client) (sarama.Consumer, error)
+}
+
+func NewQueue(brokers []string, topic, sourceTopic string) (*Queue, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("create kafka client: %w", err)
+	}
+
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("create dlq producer: %w", err)
+	}
+
+	return NewQueueFromClient(client, producer, topic, sourceTopic), nil
+}
+
+// NewQueueFromClient builds a Queue on an existing client and producer, which
+// it closes on Close. Send needs only the producer.
+func NewQueueFromClient(client sarama.Client, producer sarama.SyncProducer, topic, sourceTopic string) *Queue {
+	return &Queue{
+		client:      client,
+		producer:    producer,
+		topic:       topic,
+		sourceTopic: sourceTopic,
+		newConsumer: sarama.NewConsumerFromClient,
+	}
+}
+
+func (q *Queue) Topic() string {
+	return q.topic
+}
+
+// Send forwards the original payload of msg to the dead-letter topic.
+func (q *Queue) Send(msg *sarama.ConsumerMessage, reason error) error {
+	headers := []sarama.RecordHeader{
+		{Key: []byte(HeaderError), Value: []byte(reason.Error())},
+		{Key: []byte(HeaderSourceTopic), Value: []byte(msg.Topic)},
+		{Key: []byte(HeaderSourcePartition), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		{Key: []byte(HeaderSourceOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		{Key: []byte(HeaderFailedAt), Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	}
+
+	partition, offset, err := q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   q.topic,
+		Key:     keyEncoder(msg.Key),
+		Value:   sarama.ByteEncoder(msg.Value),
+		Headers: headers,
+	})
+	if err != nil {
+		return fmt.Errorf("send to %s: %w", q.topic, err)
+	}
+
+	log.Printf("Message %s/%d/%d sent to %s partition %d offset %d: %v",
+		msg.Topic, msg.Partition, msg.Offset, q.topic, partition, offset, reason)
+	return nil
+}
+
+// List returns up to limit entries, oldest first within each partition.
+func (q *Queue) List(limit int) ([]Entry, error) {
+	partitions, err := q.client.Partitions(q.topic)
+	if errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get partitions of %s: %w", q.topic, err)
+	}
+
+	consumer, err := q.newConsumer(q.client)
+	if err != nil {
+		return nil, fmt.Errorf("create dlq consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	entries := make([]Entry, 0)
+	for _, partition := range partitions {
+		if len(entries) >= limit {
+			break
+		}
+
+		oldest, err := q.client.GetOffset(q.topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("get oldest offset: %w", err)
+		}
+		newest, err := q.client.GetOffset(q.topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("get newest offset: %w", err)
+		}
+		if oldest >= newest {
+			continue
+		}
+
+		messages, err := q.fetch(consumer, partition, oldest, newest, limit-len(entries))
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			entries = append(entries, toEntry(msg))
+		}
+	}
+
+	return entries, nil
+}
+
+// Redrive publishes the entry stored at partition/offset back to the source
+// topic, so it goes through the main pipeline again.
+func (q *Queue) Redrive(partition int32, offset int64) error {
+	consumer, err := q.newConsumer(q.client)
+	if err != nil {
+		return fmt.Errorf("create dlq consumer: %w", err)
+	}
+	defer consumer.Close()
+
+	newest, err := q.client.GetOffset(q.topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return fmt.Errorf("get newest offset: %w", err)
+	}
+	if offset >= newest {
+		return ErrEntryNotFound
+	}
+
+	messages, err := q.fetch(consumer, partition, offset, offset+1, 1)
+	if errors.Is(err, sarama.ErrOffsetOutOfRange) {
+		return ErrEntryNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 || messages[0].Offset != offset {
+		return ErrEntryNotFound
+	}
+	msg := messages[0]
+
+	topic := q.sourceTopic
+	if source := header(msg, HeaderSourceTopic); source != "" {
+		topic = source
+	}
+
+	_, _, err = q.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   keyEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+		Headers: []sarama.RecordHeader{{
+			Key:   []byte(HeaderRedrivenFrom),
+			Value: []byte(fmt.Sprintf("%s/%d/%d", q.topic, partition, offset)),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("send to %s: %w", topic, err)
+	}
+
+	log.Printf("Redrove %s/%d/%d to %s", q.topic, partition, offset, topic)
+	return nil
+}
+
+func (q *Queue) Close() error {
+	if err := q.producer.Close(); err != nil {
+		return err
+	}
+	return q.client.Close()
+}
+
+// fetch reads messages of one partition in [from, to), at most limit of them.
+func (q *Queue) fetch(consumer sarama.Consumer, partition int32, from, to int64, limit int) ([]*sarama.ConsumerMessage, error) {
+	pc, err := consumer.ConsumePartition(q.topic, partition, from)
+	if err != nil {
+		return nil, fmt.Errorf("consume partition %d: %w", partition, err)
+	}
+	defer pc.Close()
+
+	timeout := time.NewTimer(fetchTimeout)
+	defer timeout.Stop()
+
+	var messages []*sarama.ConsumerMessage
+	for len(messages) < limit {
+		select {
+		case msg := <-pc.Messages():
+			messages = append(messages, msg)
+			if msg.Offset >= to-1 {
+				return messages, nil
+			}
+		case err := <-pc.Errors():
+			return nil, fmt.Errorf("read partition %d: %w", partition, err.Err)
+		case <-timeout.C:
+			return messages, nil
+		}
+	}
+	return messages, nil
+}
+
+func toEntry(msg *sarama.ConsumerMessage) Entry {
+	entry := Entry{
+		Partition:   msg.Partition,
+		Offset:      msg.Offset,
+		Key:         string(msg.Key),
+		Error:       header(msg, HeaderError),
+		SourceTopic: header(msg, HeaderSourceTopic),
+		Payload:     string(msg.Value),
+	}
+	if partition, err := strconv.ParseInt(header(msg, HeaderSourcePartition), 10, 32); err == nil {
+		entry.SourcePartition = int32(partition)
+	}
+	if offset, err := strconv.ParseInt(header(msg, HeaderSourceOffset), 10, 64); err == nil {
+		entry.SourceOffset = offset
+	}
+	if failedAt, err := time.Parse(time.RFC3339Nano, header(msg, HeaderFailedAt)); err == nil {
+		entry.FailedAt = failedAt
+	}
+	return entry
+}
+
+func header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func keyEncoder(key []byte) sarama.Encoder {
+	if key == nil {
+		return nil
+	}
+	return sarama.ByteEncoder(key)
+}
@@ -0,0 +1,190 @@
+package dlq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeClient serves the partitions and offsets of the dead-letter topic.
+type fakeClient struct {
+	sarama.Client
+	// offsets holds the oldest and the newest offset of each partition.
+	offsets map[int32][2]int64
+}
+
+func (c *fakeClient) Partitions(string) ([]int32, error) {
+	partitions := make([]int32, 0, len(c.offsets))
+	for partition := range int32(len(c.offsets)) {
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+func (c *fakeClient) GetOffset(_ string, partition int32, time int64) (int64, error) {
+	offsets, ok := c.offsets[partition]
+	if !ok {
+		return 0, sarama.ErrUnknownTopicOrPartition
+	}
+	if time == sarama.OffsetOldest {
+		return offsets[0], nil
+	}
+	return offsets[1], nil
+}
+
+// testQueue returns a Queue reading the dead-letter topic from consumer.
+func testQueue(t *testing.T, offsets map[int32][2]int64, consumer *mocks.Consumer, producer *mocks.SyncProducer) *Queue {
+	t.Helper()
+	queue := NewQueueFromClient(&fakeClient{offsets: offsets}, producer, "orders.dlq", "orders")
+	queue.newConsumer = func(sarama.Client) (sarama.Consumer, error) { return consumer, nil }
+	return queue
+}
+
+func deadLettered(payload string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{
+		Value: []byte(payload),
+		Headers: []*sarama.RecordHeader{
+			{Key: []byte(HeaderError), Value: []byte("invalid order")},
+			{Key: []byte(HeaderSourceTopic), Value: []byte("orders.v2")},
+			{Key: []byte(HeaderSourcePartition), Value: []byte("3")},
+			{Key: []byte(HeaderSourceOffset), Value: []byte("42")},
+		},
+	}
+}
+
+func producerHeaders(msg *sarama.ProducerMessage) map[string]string {
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return headers
+}
+
+func TestSend_Headers(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "orders.dlq" {
+			return fmt.Errorf("sent to %s, wanted orders.dlq", msg.Topic)
+		}
+		headers := producerHeaders(msg)
+		wanted := map[string]string{
+			HeaderError:           "invalid order",
+			HeaderSourceTopic:     "orders",
+			HeaderSourcePartition: "3",
+			HeaderSourceOffset:    "42",
+		}
+		for key, value := range wanted {
+			if headers[key] != value {
+				return fmt.Errorf("header %s = %q, wanted %q", key, headers[key], value)
+			}
+		}
+		if headers[HeaderFailedAt] == "" {
+			return fmt.Errorf("header %s is not set", HeaderFailedAt)
+		}
+		return nil
+	})
+	queue := NewQueueFromClient(nil, producer, "orders.dlq", "orders")
+
+	msg := &sarama.ConsumerMessage{Topic: "orders", Partition: 3, Offset: 42, Value: []byte("{}")}
+	if err := queue.Send(msg, errors.New("invalid order")); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+}
+
+func TestList_Paging(t *testing.T) {
+	offsets := map[int32][2]int64{0: {5, 7}, 1: {0, 3}}
+
+	tests := []struct {
+		name  string
+		limit int
+		// read are the partitions List has to read.
+		read []int32
+		// wanted are the partition/offset pairs of the listed entries.
+		wanted []string
+	}{
+		{"WithinFirstPartition", 1, []int32{0}, []string{"0/5"}},
+		{"AcrossPartitions", 3, []int32{0, 1}, []string{"0/5", "0/6", "1/0"}},
+		{"AllEntries", 10, []int32{0, 1}, []string{"0/5", "0/6", "1/0", "1/1", "1/2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consumer := mocks.NewConsumer(t, nil)
+			for _, partition := range tt.read {
+				bounds := offsets[partition]
+				pc := consumer.ExpectConsumePartition("orders.dlq", partition, bounds[0])
+				for offset := bounds[0]; offset < bounds[1]; offset++ {
+					pc.YieldMessage(deadLettered(fmt.Sprintf("payload %d/%d", partition, offset)))
+				}
+			}
+			queue := testQueue(t, offsets, consumer, nil)
+
+			entries, err := queue.List(tt.limit)
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, fmt.Sprintf("%d/%d", entry.Partition, entry.Offset))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wanted) {
+				t.Errorf("Listed %v, wanted %v", got, tt.wanted)
+			}
+			if entries[0].SourceTopic != "orders.v2" || entries[0].SourceOffset != 42 || entries[0].Error != "invalid order" {
+				t.Errorf("Entry headers are not decoded: %+v", entries[0])
+			}
+		})
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(msg *sarama.ProducerMessage) error {
+		if msg.Topic != "orders.v2" {
+			return fmt.Errorf("redriven to %s, wanted the source topic orders.v2", msg.Topic)
+		}
+		if from := producerHeaders(msg)[HeaderRedrivenFrom]; from != "orders.dlq/0/1" {
+			return fmt.Errorf("header %s = %q", HeaderRedrivenFrom, from)
+		}
+		return nil
+	})
+	consumer := mocks.NewConsumer(t, nil)
+	consumer.ExpectConsumePartition("orders.dlq", 0, 1).YieldMessage(deadLettered("payload"))
+	queue := testQueue(t, map[int32][2]int64{0: {0, 2}}, consumer, producer)
+
+	if err := queue.Redrive(0, 1); err != nil {
+		t.Fatalf("Redrive() error = %v", err)
+	}
+}
+
+func TestRedrive_EntryNotFound(t *testing.T) {
+	t.Run("PastNewestOffset", func(t *testing.T) {
+		queue := testQueue(t, map[int32][2]int64{0: {0, 2}}, mocks.NewConsumer(t, nil), nil)
+
+		if err := queue.Redrive(0, 2); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("Redrive() error = %v, wanted ErrEntryNotFound", err)
+		}
+	})
+	t.Run("OffsetOutOfRange", func(t *testing.T) {
+		consumer := mocks.NewConsumer(t, nil)
+		consumer.ExpectConsumePartition("orders.dlq", 0, 0).YieldError(sarama.ErrOffsetOutOfRange)
+		queue := testQueue(t, map[int32][2]int64{0: {0, 2}}, consumer, nil)
+
+		if err := queue.Redrive(0, 0); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("Redrive() error = %v, wanted ErrEntryNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,258 @@
+// Package migrations keeps the database schema as versioned SQL files
+// embedded into the binary and applies them in order.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Every step runs in its own transaction together with the update of the
+// schema_migrations table, so a failed step leaves no trace.
+package migrations
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// lockKey is the pg_advisory_lock key that keeps instances starting at the
+// same time from migrating concurrently.
+const lockKey int64 = 0x6f72646572730001
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes one known migration and whether it is applied.
+type Status struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []Migration
+}
+
+// Connect opens a dedicated connection; the advisory lock is held by the
+// session, so it must not come from a pool.
+func Connect(ctx context.Context, connStr string) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{conn: conn, migrations: migrations}, nil
+}
+
+func (migrator *Migrator) Close(ctx context.Context) error {
+	return migrator.conn.Close(ctx)
+}
+
+// Up applies every migration that is not applied yet and returns how many
+// were applied.
+func (migrator *Migrator) Up(ctx context.Context) (int, error) {
+	count := 0
+	err := migrator.locked(ctx, func(applied map[int]time.Time) error {
+		for _, migration := range migrator.migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			log.Printf("Applying migration %04d_%s", migration.Version, migration.Name)
+			err := migrator.apply(ctx, migration.Up,
+				`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+				migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// Down reverts up to steps most recently applied migrations and returns how
+// many were reverted.
+func (migrator *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	count := 0
+	err := migrator.locked(ctx, func(applied map[int]time.Time) error {
+		for i := len(migrator.migrations) - 1; i >= 0 && count < steps; i-- {
+			migration := migrator.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			log.Printf("Reverting migration %04d_%s", migration.Version, migration.Name)
+			err := migrator.apply(ctx, migration.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`,
+				migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func (migrator *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := migrator.locked(ctx, func(applied map[int]time.Time) error {
+		for _, migration := range migrator.migrations {
+			status := Status{Version: migration.Version, Name: migration.Name}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// locked runs fn under the advisory lock with the set of applied versions.
+func (migrator *Migrator) locked(ctx context.Context, fn func(applied map[int]time.Time) error) error {
+	if _, err := migrator.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// The lock goes away with the session anyway, so an error here is
+		// only logged.
+		if _, err := migrator.conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			log.Printf("Failed to release migration lock: %v", err)
+		}
+	}()
+
+	if _, err := migrator.conn.Exec(ctx, createMigrationsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := migrator.conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	applied := make(map[int]time.Time)
+	var version int
+	var appliedAt time.Time
+	_, err = pgx.ForEachRow(rows, []any{&version, &appliedAt}, func() error {
+		applied[version] = appliedAt
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return fn(applied)
+}
+
+// apply runs the migration script and the bookkeeping statement in one
+// transaction.
+func (migrator *Migrator) apply(ctx context.Context, script, bookkeeping string, args ...any) error {
+	tx, err := migrator.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// load reads the migrations from fsys and checks that every version has
+// both steps and versions are unique.
+func load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, name := range names {
+		base := path.Base(name)
+		stem, direction, ok := cutDirection(base)
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must end with .up.sql or .down.sql", base)
+		}
+		versionText, migrationName, ok := strings.Cut(stem, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: name must be <version>_<name>", base)
+		}
+		version, err := strconv.Atoi(versionText)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", base, versionText)
+		}
+
+		script, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exist := byVersion[version]
+		if !exist {
+			migration = &Migration{Version: version, Name: migrationName}
+			byVersion[version] = migration
+		} else if migration.Name != migrationName {
+			return nil, fmt.Errorf("migration %s: version %d is also used by %q", base, version, migration.Name)
+		}
+
+		step := &migration.Up
+		if direction == "down" {
+			step = &migration.Down
+		}
+		if *step != "" {
+			return nil, fmt.Errorf("migration %s: duplicate %s step", base, direction)
+		}
+		*step = string(script)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s: both up and down steps are required", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+func cutDirection(name string) (stem, direction string, ok bool) {
+	if stem, ok = strings.CutSuffix(name, ".up.sql"); ok {
+		return stem, "up", true
+	}
+	if stem, ok = strings.CutSuffix(name, ".down.sql"); ok {
+		return stem, "down", true
+	}
+	return "", "", false
+}
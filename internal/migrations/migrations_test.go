@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("Embedded migrations are invalid: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("No migrations are embedded")
+	}
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("Migration %d has version %d, versions must be consecutive", i, migration.Version)
+		}
+	}
+}
+
+func TestLoad_SortsByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/0010_later.up.sql":   {Data: []byte("up 10")},
+		"sql/0010_later.down.sql": {Data: []byte("down 10")},
+		"sql/0002_first.up.sql":   {Data: []byte("up 2")},
+		"sql/0002_first.down.sql": {Data: []byte("down 2")},
+	}
+
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Fatalf("Got %+v, wanted versions 2 and 10", migrations)
+	}
+	if migrations[0].Name != "first" || migrations[0].Up != "up 2" || migrations[0].Down != "down 2" {
+		t.Errorf("Got %+v", migrations[0])
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		files fstest.MapFS
+		want  string
+	}{
+		"missing down": {
+			files: fstest.MapFS{"sql/0001_init.up.sql": {Data: []byte("up")}},
+			want:  "both up and down",
+		},
+		"bad suffix": {
+			files: fstest.MapFS{"sql/0001_init.sql": {Data: []byte("up")}},
+			want:  ".up.sql or .down.sql",
+		},
+		"bad version": {
+			files: fstest.MapFS{"sql/first_init.up.sql": {Data: []byte("up")}},
+			want:  "invalid version",
+		},
+		"version reused": {
+			files: fstest.MapFS{
+				"sql/0001_init.up.sql":    {Data: []byte("up")},
+				"sql/0001_init.down.sql":  {Data: []byte("down")},
+				"sql/0001_other.up.sql":   {Data: []byte("up")},
+				"sql/0001_other.down.sql": {Data: []byte("down")},
+			},
+			want: "also used by",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := load(test.files)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Got %v, wanted error containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+-- Fractions of a unit are truncated, as the INTEGER columns did before.
+ALTER TABLE payments
+    ALTER COLUMN amount        TYPE INTEGER USING (amount / 100)::INTEGER,
+    ALTER COLUMN delivery_cost TYPE INTEGER USING (delivery_cost / 100)::INTEGER,
+    ALTER COLUMN goods_total   TYPE INTEGER USING (goods_total / 100)::INTEGER,
+    ALTER COLUMN custom_fee    TYPE INTEGER USING (custom_fee / 100)::INTEGER;
+
+ALTER TABLE items
+    ALTER COLUMN price       TYPE INTEGER USING (price / 100)::INTEGER,
+    ALTER COLUMN total_price TYPE INTEGER USING (total_price / 100)::INTEGER;
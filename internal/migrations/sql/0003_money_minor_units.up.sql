@@ -0,0 +1,11 @@
+-- Money used to be whole units in INTEGER columns, it is kept in minor
+-- units (hundredths) now.
+ALTER TABLE payments
+    ALTER COLUMN amount        TYPE BIGINT USING amount::BIGINT * 100,
+    ALTER COLUMN delivery_cost TYPE BIGINT USING delivery_cost::BIGINT * 100,
+    ALTER COLUMN goods_total   TYPE BIGINT USING goods_total::BIGINT * 100,
+    ALTER COLUMN custom_fee    TYPE BIGINT USING custom_fee::BIGINT * 100;
+
+ALTER TABLE items
+    ALTER COLUMN price       TYPE BIGINT USING price::BIGINT * 100,
+    ALTER COLUMN total_price TYPE BIGINT USING total_price::BIGINT * 100;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS orders_delivery_service_date_created_idx;
+DROP INDEX IF EXISTS orders_customer_id_date_created_idx;
+DROP INDEX IF EXISTS orders_date_created_idx;
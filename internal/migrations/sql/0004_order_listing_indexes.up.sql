@@ -0,0 +1,11 @@
+-- Keyset pagination of GET /orders walks (date_created, order_uid) backwards,
+-- optionally within one customer or delivery service. Locale, sm_id and
+-- currency have few distinct values and are filtered along the date index.
+CREATE INDEX IF NOT EXISTS orders_date_created_idx
+    ON "orders" (date_created DESC, order_uid DESC);
+
+CREATE INDEX IF NOT EXISTS orders_customer_id_date_created_idx
+    ON "orders" (customer_id, date_created DESC, order_uid DESC);
+
+CREATE INDEX IF NOT EXISTS orders_delivery_service_date_created_idx
+    ON "orders" (delivery_service, date_created DESC, order_uid DESC);
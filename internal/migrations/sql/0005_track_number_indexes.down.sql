@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS items_track_number_idx;
+DROP INDEX IF EXISTS orders_track_number_idx;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS orders_track_number_idx ON "orders" (track_number);
+
+CREATE INDEX IF NOT EXISTS items_track_number_idx ON "items" (track_number);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS deliveries_email_trgm_idx;
+DROP INDEX IF EXISTS deliveries_phone_trgm_idx;
+DROP INDEX IF EXISTS items_search_idx;
+DROP INDEX IF EXISTS deliveries_search_idx;
+
+ALTER TABLE items DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE deliveries DROP COLUMN IF EXISTS search_vector;
+
+-- pg_trgm is left installed, other objects may use it.
@@ -0,0 +1,21 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- The 'simple' configuration does no stemming: names, cities and brands are
+-- not words of any one language.
+ALTER TABLE deliveries ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', city || ' ' || region), 'B') ||
+    setweight(to_tsvector('simple', address), 'C')
+) STORED;
+
+ALTER TABLE items ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', brand), 'B')
+) STORED;
+
+CREATE INDEX deliveries_search_idx ON deliveries USING GIN (search_vector);
+CREATE INDEX items_search_idx ON items USING GIN (search_vector);
+
+-- Phones and emails are searched by substring.
+CREATE INDEX deliveries_phone_trgm_idx ON deliveries USING GIN (phone gin_trgm_ops);
+CREATE INDEX deliveries_email_trgm_idx ON deliveries USING GIN (email gin_trgm_ops);
@@ -0,0 +1,85 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy describes jittered exponential backoff. The n-th retry waits
+// InitialInterval*Multiplier^(n-1), capped by MaxInterval, then spread by
+// ±Jitter of that value.
+type Policy struct {
+	// MaxAttempts includes the first attempt; 0 retries until ctx is done.
+	MaxAttempts     int
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	Multiplier      float64
+	Jitter          float64
+}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     5,
+		InitialInterval: 200 * time.Millisecond,
+		MaxInterval:     10 * time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+	}
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable,
+// MaxAttempts is reached or ctx is done. onRetry, if set, is called before
+// every wait with the failed attempt number and its error. The last error of
+// fn is returned, also when ctx ends during a wait; ctx is not checked
+// before the first attempt.
+func (p Policy) Do(ctx context.Context, retryable func(error) bool, onRetry func(attempt int, err error), fn func() error) error {
+	attempt := 0
+	for {
+		attempt++
+		err := fn()
+		if err == nil || !retryable(err) {
+			return err
+		}
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt, err)
+		}
+
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// Backoff returns the delay after the given failed attempt, starting from 1.
+func (p Policy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialInterval) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxInterval > 0 && delay > float64(p.MaxInterval) {
+		delay = float64(p.MaxInterval)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,128 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func fastPolicy(attempts int) Policy {
+	return Policy{
+		MaxAttempts:     attempts,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     2 * time.Millisecond,
+		Multiplier:      2,
+	}
+}
+
+func TestRetry_SucceedsAfterTransientErrors(t *testing.T) {
+	calls := 0
+	retries := 0
+	err := fastPolicy(5).Do(context.Background(), isTransient,
+		func(int, error) { retries++ },
+		func() error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+	if calls != 3 || retries != 2 {
+		t.Errorf("Expected 3 calls and 2 retries, got %d and %d", calls, retries)
+	}
+}
+
+func TestRetry_StopsOnPermanentError(t *testing.T) {
+	calls := 0
+	err := fastPolicy(5).Do(context.Background(), isTransient, nil, func() error {
+		calls++
+		return errPermanent
+	})
+	if !errors.Is(err, errPermanent) {
+		t.Errorf("Expected permanent error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Permanent error should not be retried, got %d calls", calls)
+	}
+}
+
+func TestRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	calls := 0
+	err := fastPolicy(4).Do(context.Background(), isTransient, nil, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Errorf("Expected transient error, got %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 calls, got %d", calls)
+	}
+}
+
+func TestRetry_StopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := Policy{InitialInterval: time.Hour, Multiplier: 2}
+
+	calls := 0
+	err := policy.Do(ctx, isTransient, func(int, error) { cancel() }, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Errorf("Expected last error of fn, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call, got %d", calls)
+	}
+}
+
+func TestRetry_BackoffGrowsAndIsCapped(t *testing.T) {
+	policy := Policy{
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     time.Second,
+		Multiplier:      2,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := policy.Backoff(i + 1); got != want {
+			t.Errorf("Backoff(%d) = %v, wanted %v", i+1, got, want)
+		}
+	}
+}
+
+func TestRetry_BackoffJitterStaysInRange(t *testing.T) {
+	policy := Policy{
+		InitialInterval: 100 * time.Millisecond,
+		Multiplier:      2,
+		Jitter:          0.5,
+	}
+
+	for i := 0; i < 1000; i++ {
+		got := policy.Backoff(2)
+		if got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("Backoff with jitter out of range: %v", got)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// itemColumns are the columns written by COPY, in the order of itemRow.
+var itemColumns = []string{
+	"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+	"sale", "size", "total_price", "nm_id", "brand", "status",
+}
+
+// errBatchConflict means another writer inserted one of the batch's new
+// orders after the batch looked them up.
+var errBatchConflict = errors.New("order inserted concurrently")
+
+// InsertBatch stores many orders in one transaction. Each order gets the
+// outcome InsertToDB would report for it if the orders were inserted one
+// after another, so an order repeated in the batch is compared with its
+// previous version and only the last version is written.
+//
+// Order, delivery and payment rows go through one pgx.Batch, items through
+// COPY. If the batch races with a concurrent insert of the same order, it
+// falls back to inserting the orders one by one.
+func (repository *Repository) InsertBatch(orders []*models.Order) ([]InsertOutcome, error) {
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	outcomes, err := repository.insertBatch(orders)
+	if errors.Is(err, errBatchConflict) {
+		log.Printf("Batch of %d orders conflicts with a concurrent insert, storing one by one", len(orders))
+		return insertEach(repository, orders)
+	}
+	return outcomes, err
+}
+
+func (repository *Repository) insertBatch(orders []*models.Order) ([]InsertOutcome, error) {
+	ctx := context.Background()
+
+	hashes := make([]string, len(orders))
+	uids := make([]string, len(orders))
+	for i, order := range orders {
+		hash, err := contentHash(order)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+		uids[i] = order.OrderUID
+	}
+
+	acquireCtx, cancel := context.WithTimeout(ctx, acquireTimeout)
+	defer cancel()
+	conn, err := repository.pool.Acquire(acquireCtx)
+	if err != nil {
+		log.Printf("Unable to get connection from the Pool: %v", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	stored, err := selectStoredHashes(ctx, tx, uids)
+	if err != nil {
+		log.Printf("Error reading stored orders: %v", err)
+		return nil, err
+	}
+
+	outcomes := make([]InsertOutcome, len(orders))
+	latest := make(map[string]*string, len(stored))
+	for uid, hash := range stored {
+		latest[uid] = hash
+	}
+	written := make(map[string]int)
+	for i, uid := range uids {
+		previous, exist := latest[uid]
+		switch {
+		case !exist:
+			outcomes[i] = OrderInserted
+		case previous != nil && *previous == hashes[i]:
+			outcomes[i] = OrderUnchanged
+			continue
+		default:
+			outcomes[i] = OrderUpdated
+		}
+		latest[uid] = &hashes[i]
+		written[uid] = i
+	}
+	if len(written) == 0 {
+		return outcomes, nil
+	}
+
+	batch := &pgx.Batch{}
+	var replaced []string
+	for uid := range written {
+		if _, exist := stored[uid]; exist {
+			replaced = append(replaced, uid)
+		}
+	}
+	if len(replaced) > 0 {
+		batch.Queue(deleteItemsOfOrders, replaced)
+	}
+
+	var itemRows [][]any
+	for i, order := range orders {
+		if last, ok := written[order.OrderUID]; !ok || last != i {
+			continue
+		}
+		if err := repository.queueOrder(batch, order, hashes[i], stored); err != nil {
+			return nil, err
+		}
+		for j := range order.Items {
+			itemRows = append(itemRows, itemRow(order.OrderUID, &order.Items[j]))
+		}
+	}
+
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		if !errors.Is(err, errBatchConflict) {
+			log.Printf("Error writing batch of orders: %v", err)
+		}
+		return nil, err
+	}
+
+	if len(itemRows) > 0 {
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"items"}, itemColumns, pgx.CopyFromRows(itemRows))
+		if err != nil {
+			log.Printf("Error copying items: %v", err)
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return nil, err
+	}
+
+	for _, i := range written {
+		repository.cacheWritten(orders[i])
+	}
+	log.Printf("Batch of %d orders is stored, %d written", len(orders), len(written))
+	return outcomes, nil
+}
+
+// queueOrder queues the statements InsertToDB runs for one order, except for
+// the items.
+func (repository *Repository) queueOrder(batch *pgx.Batch, order *models.Order, hash string, stored map[string]*string) error {
+	op := opUpdate
+	if _, exist := stored[order.OrderUID]; exist {
+		batch.Queue(updateOrder,
+			order.OrderUID, order.TrackNumber, order.Entry,
+			order.Locale, order.InternalSignature, order.CustomerID,
+			order.DeliveryService, order.Shardkey, order.SmID,
+			order.DateCreated, order.OofShard, hash)
+	} else {
+		op = opInsert
+		batch.Queue(insertOrder,
+			order.OrderUID, order.TrackNumber, order.Entry,
+			order.Locale, order.InternalSignature, order.CustomerID,
+			order.DeliveryService, order.Shardkey, order.SmID,
+			order.DateCreated, order.OofShard, hash,
+		).Exec(func(tag pgconn.CommandTag) error {
+			if tag.RowsAffected() == 0 {
+				return errBatchConflict
+			}
+			return nil
+		})
+	}
+
+	delivery := &order.Delivery
+	batch.Queue(insertDelivery,
+		order.OrderUID, delivery.Name, delivery.Phone,
+		delivery.Zip, delivery.City, delivery.Address,
+		delivery.Region, delivery.Email)
+
+	payment := &order.Payment
+	batch.Queue(insertPayment,
+		order.OrderUID, payment.Transaction, payment.RequestID,
+		payment.Currency, payment.Provider, payment.Amount,
+		payment.PaymentDt, payment.Bank, payment.DeliveryCost,
+		payment.GoodsTotal, payment.CustomFee)
+
+	payload, err := repository.changePayload(op, order.OrderUID, orderTracks(order))
+	if err != nil {
+		return err
+	}
+	batch.Queue(notifyOrderChange, ordersChannel, payload)
+	return nil
+}
+
+// selectStoredHashes locks the stored orders among uids and returns their
+// content hashes; the hash is nil for orders written before it existed.
+func selectStoredHashes(ctx context.Context, tx pgx.Tx, uids []string) (map[string]*string, error) {
+	rows, err := tx.Query(ctx, selectOrderHashes, uids)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*string)
+	var uid string
+	var hash *string
+	_, err = pgx.ForEachRow(rows, []any{&uid, &hash}, func() error {
+		// The scan target is reused between rows, so the hash is copied.
+		if hash == nil {
+			stored[uid] = nil
+		} else {
+			copied := *hash
+			stored[uid] = &copied
+		}
+		return nil
+	})
+	return stored, err
+}
+
+func itemRow(orderUid string, item *models.Item) []any {
+	return []any{
+		orderUid, item.ChrtID, item.TrackNumber,
+		item.Price, item.Rid, item.Name, item.Sale,
+		item.Size, item.TotalPrice, item.NmID,
+		item.Brand, item.Status,
+	}
+}
+
+// insertEach stores the orders one at a time with InsertToDB.
+func insertEach(store OrderStore, orders []*models.Order) ([]InsertOutcome, error) {
+	outcomes := make([]InsertOutcome, len(orders))
+	for i, order := range orders {
+		outcome, err := store.InsertToDB(order)
+		if err != nil {
+			return nil, err
+		}
+		outcomes[i] = outcome
+	}
+	return outcomes, nil
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CustomerSummary aggregates every order of a customer.
+type CustomerSummary struct {
+	CustomerID string `json:"customer_id"`
+	OrderCount int    `json:"order_count"`
+	// Totals sums payment.amount per currency; amounts in different
+	// currencies are never added up.
+	Totals map[string]models.Money `json:"totals"`
+	// FirstOrderAt and LastOrderAt are the range of date_created, nil when
+	// the customer has no orders.
+	FirstOrderAt *time.Time `json:"first_order_at,omitempty"`
+	LastOrderAt  *time.Time `json:"last_order_at,omitempty"`
+}
+
+// CustomerSummary computes the summary with one query: the empty grouping
+// set gives the overall count and dates, the currency one the totals.
+func (repository *Repository) CustomerSummary(customerID string) (CustomerSummary, error) {
+	summary := CustomerSummary{CustomerID: customerID, Totals: map[string]models.Money{}}
+
+	rows, err := repository.pool.Query(context.Background(), selectCustomerSummary, customerID)
+	if err != nil {
+		return summary, fmt.Errorf("query customer summary: %w", err)
+	}
+
+	var overall bool
+	var currency *string
+	var count int
+	var total *int64
+	var first, last *time.Time
+	_, err = pgx.ForEachRow(rows, []any{&overall, &currency, &count, &total, &first, &last}, func() error {
+		if overall {
+			summary.OrderCount = count
+			summary.FirstOrderAt = copyTime(first)
+			summary.LastOrderAt = copyTime(last)
+			return nil
+		}
+		// Orders without a payment have no currency to total in.
+		if currency != nil && total != nil {
+			summary.Totals[*currency] = models.Money(*total)
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, fmt.Errorf("collect customer summary: %w", err)
+	}
+	return summary, nil
+}
+
+func copyTime(value *time.Time) *time.Time {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
@@ -0,0 +1,59 @@
+package storage
+
+import "sync"
+
+// generationStripes bounds the memory of generations; uids sharing a stripe
+// only make some loads skip the cache.
+const generationStripes = 1024
+
+// generations counts the writes to orders, so a database load that raced
+// with a write can tell that its result may be stale and must not be cached.
+// A load reads the generation before it queries the database and caches the
+// result only if the generation is still the same.
+type generations struct {
+	stripes [generationStripes]generationStripe
+}
+
+type generationStripe struct {
+	mu  sync.Mutex
+	gen uint64
+}
+
+func (g *generations) stripe(orderUid string) *generationStripe {
+	// FNV-1a, as in cache.Cache.shardFor.
+	hash := uint32(2166136261)
+	for i := 0; i < len(orderUid); i++ {
+		hash ^= uint32(orderUid[i])
+		hash *= 16777619
+	}
+	return &g.stripes[hash%generationStripes]
+}
+
+// current returns the generation a load of orderUid starts from.
+func (g *generations) current(orderUid string) uint64 {
+	stripe := g.stripe(orderUid)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	return stripe.gen
+}
+
+// bump starts a new generation of orderUid and runs update, which brings the
+// caches up to date with the write, before any stale load can cache its
+// result.
+func (g *generations) bump(orderUid string, update func()) {
+	stripe := g.stripe(orderUid)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	stripe.gen++
+	update()
+}
+
+// ifCurrent runs store only if orderUid was not written since gen.
+func (g *generations) ifCurrent(orderUid string, gen uint64, store func()) {
+	stripe := g.stripe(orderUid)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+	if stripe.gen == gen {
+		store()
+	}
+}
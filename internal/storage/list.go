@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last order of a page; the next page starts right
+// after it in (date_created, order_uid) descending order.
+type Cursor struct {
+	DateCreated time.Time
+	OrderUID    string
+}
+
+// OrderFilter narrows ListOrders down. Empty fields match every order.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	SmID            *int
+	// Currency is the currency of the order's payment.
+	Currency string
+	// CreatedFrom is inclusive and CreatedTo is exclusive.
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+}
+
+type ListQuery struct {
+	Limit int
+	// After is nil for the first page.
+	After  *Cursor
+	Filter OrderFilter
+}
+
+// CursorOf returns the cursor pointing right after order.
+func CursorOf(order *models.Order) *Cursor {
+	return &Cursor{DateCreated: order.DateCreated, OrderUID: order.OrderUID}
+}
+
+type encodedCursor struct {
+	DateCreated time.Time `json:"t"`
+	OrderUID    string    `json:"u"`
+}
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (cursor *Cursor) Encode() string {
+	data, _ := json.Marshal(encodedCursor{DateCreated: cursor.DateCreated, OrderUID: cursor.OrderUID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token made by Cursor.Encode.
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var decoded encodedCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.OrderUID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{DateCreated: decoded.DateCreated, OrderUID: decoded.OrderUID}, nil
+}
+
+// before reports whether order goes before the cursor position, i.e. whether
+// it belongs to a later page.
+func (cursor *Cursor) before(order *models.Order) bool {
+	if !order.DateCreated.Equal(cursor.DateCreated) {
+		return order.DateCreated.Before(cursor.DateCreated)
+	}
+	return order.OrderUID < cursor.OrderUID
+}
+
+// matches is the in-memory version of the WHERE clause built by
+// listOrderUIDsQuery.
+func (filter *OrderFilter) matches(order *models.Order) bool {
+	switch {
+	case filter.CustomerID != "" && order.CustomerID != filter.CustomerID,
+		filter.DeliveryService != "" && order.DeliveryService != filter.DeliveryService,
+		filter.Locale != "" && order.Locale != filter.Locale,
+		filter.SmID != nil && order.SmID != *filter.SmID,
+		filter.Currency != "" && order.Payment.Currency != filter.Currency,
+		!filter.CreatedFrom.IsZero() && order.DateCreated.Before(filter.CreatedFrom),
+		!filter.CreatedTo.IsZero() && !order.DateCreated.Before(filter.CreatedTo):
+		return false
+	}
+	return true
+}
+
+// listOrderUIDsQuery builds the page query with a condition per set filter,
+// so the planner can pick the matching index.
+func listOrderUIDsQuery(query ListQuery) (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, values ...any) {
+		for _, value := range values {
+			args = append(args, value)
+			condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(args)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+
+	filter := &query.Filter
+	if filter.CustomerID != "" {
+		add("o.customer_id = ?", filter.CustomerID)
+	}
+	if filter.DeliveryService != "" {
+		add("o.delivery_service = ?", filter.DeliveryService)
+	}
+	if filter.Locale != "" {
+		add("o.locale = ?", filter.Locale)
+	}
+	if filter.SmID != nil {
+		add("o.sm_id = ?", *filter.SmID)
+	}
+	if filter.Currency != "" {
+		add(`EXISTS (SELECT 1 FROM "payments" p WHERE p.order_uid = o.order_uid AND p.currency = ?)`, filter.Currency)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		add("o.date_created >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		add("o.date_created < ?", filter.CreatedTo)
+	}
+	if query.After != nil {
+		add("(o.date_created, o.order_uid) < (?, ?)", query.After.DateCreated, query.After.OrderUID)
+	}
+
+	var sql strings.Builder
+	sql.WriteString(`SELECT o.order_uid FROM "orders" o`)
+	if len(conditions) > 0 {
+		sql.WriteString(" WHERE ")
+		sql.WriteString(strings.Join(conditions, " AND "))
+	}
+	sql.WriteString(" ORDER BY o.date_created DESC, o.order_uid DESC")
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		fmt.Fprintf(&sql, " LIMIT $%d", len(args))
+	}
+	return sql.String(), args
+}
+
+func (repository *Repository) ListOrders(query ListQuery) ([]models.Order, error) {
+	ctx := context.Background()
+
+	sql, args := listOrderUIDsQuery(query)
+	rows, err := repository.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query order uids: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("collect order uids: %w", err)
+	}
+
+	loaded, err := repository.loadOrders(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		if order, found := loaded[uid]; found {
+			orders = append(orders, *order)
+		}
+	}
+	return orders, nil
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	cursor := &Cursor{
+		DateCreated: time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC),
+		OrderUID:    "b563feb7b2b84b6test",
+	}
+
+	decoded, err := DecodeCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !decoded.DateCreated.Equal(cursor.DateCreated) || decoded.OrderUID != cursor.OrderUID {
+		t.Errorf("Decoded %+v, wanted %+v", decoded, cursor)
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, token := range []string{"not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := DecodeCursor(token); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) = %v, wanted ErrInvalidCursor", token, err)
+		}
+	}
+}
+
+func TestListOrderUIDsQuery(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	after := &Cursor{DateCreated: from.Add(time.Hour), OrderUID: "order-b"}
+
+	sql, args := listOrderUIDsQuery(ListQuery{
+		Limit:  10,
+		After:  after,
+		Filter: OrderFilter{CustomerID: "alice", Currency: "EUR", CreatedFrom: from},
+	})
+
+	want := `SELECT o.order_uid FROM "orders" o WHERE o.customer_id = $1` +
+		` AND EXISTS (SELECT 1 FROM "payments" p WHERE p.order_uid = o.order_uid AND p.currency = $2)` +
+		` AND o.date_created >= $3 AND (o.date_created, o.order_uid) < ($4, $5)` +
+		` ORDER BY o.date_created DESC, o.order_uid DESC LIMIT $6`
+	if sql != want {
+		t.Errorf("Got query\n%s\nwanted\n%s", sql, want)
+	}
+	wantArgs := []any{"alice", "EUR", from, after.DateCreated, "order-b", 10}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("Got args %v, wanted %v", args, wantArgs)
+	}
+}
+
+func TestListOrderUIDsQuery_NoFilter(t *testing.T) {
+	sql, args := listOrderUIDsQuery(ListQuery{})
+	want := `SELECT o.order_uid FROM "orders" o ORDER BY o.date_created DESC, o.order_uid DESC`
+	if sql != want || len(args) != 0 {
+		t.Errorf("Got %q with %v, wanted %q without args", sql, args, want)
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"test-task/pkg/models"
+)
+
+// MemoryStore is an OrderStore kept entirely in memory, with the same
+// semantics as Repository. It is meant for tests and local runs.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	orders map[string]*storedOrder
+	nextID int
+}
+
+type storedOrder struct {
+	order models.Order
+	hash  string
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{orders: make(map[string]*storedOrder)}
+}
+
+func (store *MemoryStore) InsertToDB(order *models.Order) (InsertOutcome, error) {
+	hash, err := contentHash(order)
+	if err != nil {
+		return 0, err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	outcome := OrderInserted
+	if existing, exist := store.orders[order.OrderUID]; exist {
+		if existing.hash == hash {
+			return OrderUnchanged, nil
+		}
+		outcome = OrderUpdated
+	}
+
+	stored := copyOrder(order)
+	stored.Delivery.OrderUID = stored.OrderUID
+	stored.Payment.OrderUID = stored.OrderUID
+	for i := range stored.Items {
+		store.nextID++
+		stored.Items[i].ID = store.nextID
+		stored.Items[i].OrderUID = stored.OrderUID
+	}
+	store.orders[order.OrderUID] = &storedOrder{order: stored, hash: hash}
+	return outcome, nil
+}
+
+func (store *MemoryStore) InsertBatch(orders []*models.Order) ([]InsertOutcome, error) {
+	return insertEach(store, orders)
+}
+
+func (store *MemoryStore) FindOrderById(orderUid string) (models.Order, bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	stored, exist := store.orders[orderUid]
+	if !exist {
+		return models.Order{}, false, nil
+	}
+	return copyOrder(&stored.order), true, nil
+}
+
+func (store *MemoryStore) FindOrdersByTrack(trackNumber string) ([]models.Order, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var orders []models.Order
+	for _, stored := range store.orders {
+		if HasTrack(&stored.order, trackNumber) {
+			orders = append(orders, copyOrder(&stored.order))
+		}
+	}
+	sortNewestFirst(orders)
+	if len(orders) > maxOrdersPerTrack {
+		orders = orders[:maxOrdersPerTrack]
+	}
+	return orders, nil
+}
+
+func (store *MemoryStore) ListOrders(query ListQuery) ([]models.Order, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	orders := make([]models.Order, 0, len(store.orders))
+	for _, stored := range store.orders {
+		if query.After != nil && !query.After.before(&stored.order) {
+			continue
+		}
+		if !query.Filter.matches(&stored.order) {
+			continue
+		}
+		orders = append(orders, copyOrder(&stored.order))
+	}
+
+	sortNewestFirst(orders)
+	if query.Limit > 0 && len(orders) > query.Limit {
+		orders = orders[:query.Limit]
+	}
+	return orders, nil
+}
+
+func (store *MemoryStore) SearchOrders(query SearchQuery) ([]OrderSummary, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	var summaries []OrderSummary
+	for _, stored := range store.orders {
+		if summary, matched := summarize(&stored.order, query.Text); matched {
+			summaries = append(summaries, summary)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Rank != summaries[j].Rank {
+			return summaries[i].Rank > summaries[j].Rank
+		}
+		cursor := Cursor{DateCreated: summaries[i].DateCreated, OrderUID: summaries[i].OrderUID}
+		return cursor.before(&models.Order{DateCreated: summaries[j].DateCreated, OrderUID: summaries[j].OrderUID})
+	})
+	if query.Limit > 0 && len(summaries) > query.Limit {
+		summaries = summaries[:query.Limit]
+	}
+	return summaries, nil
+}
+
+func (store *MemoryStore) CustomerSummary(customerID string) (CustomerSummary, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	summary := CustomerSummary{CustomerID: customerID, Totals: map[string]models.Money{}}
+	for _, stored := range store.orders {
+		order := &stored.order
+		if order.CustomerID != customerID {
+			continue
+		}
+		summary.OrderCount++
+		summary.Totals[order.Payment.Currency] += order.Payment.Amount
+		if summary.FirstOrderAt == nil || order.DateCreated.Before(*summary.FirstOrderAt) {
+			summary.FirstOrderAt = copyTime(&order.DateCreated)
+		}
+		if summary.LastOrderAt == nil || order.DateCreated.After(*summary.LastOrderAt) {
+			summary.LastOrderAt = copyTime(&order.DateCreated)
+		}
+	}
+	return summary, nil
+}
+
+func (store *MemoryStore) DeleteOrder(orderUid string) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	_, exist := store.orders[orderUid]
+	delete(store.orders, orderUid)
+	return exist, nil
+}
+
+func (store *MemoryStore) Close() {}
+
+func sortNewestFirst(orders []models.Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		return CursorOf(&orders[i]).before(&orders[j])
+	})
+}
+
+func copyOrder(order *models.Order) models.Order {
+	copied := *order
+	copied.Items = append([]models.Item(nil), order.Items...)
+	return copied
+}
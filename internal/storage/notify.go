@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"test-task/internal/retry"
+)
+
+// ordersChannel is the Postgres NOTIFY channel announcing order changes to
+// every service instance sharing the database.
+const ordersChannel = "orders_changed"
+
+type changeOp string
+
+const (
+	opInsert changeOp = "insert"
+	opUpdate changeOp = "update"
+	opDelete changeOp = "delete"
+)
+
+type orderChange struct {
+	Op       changeOp `json:"op"`
+	OrderUID string   `json:"order_uid"`
+	// Origin is the instance that made the change; it ignores its own
+	// notifications because it has already updated its cache.
+	Origin string `json:"origin"`
+	// Tracks are the track numbers of an inserted or updated order.
+	Tracks []string `json:"tracks,omitempty"`
+}
+
+var listenRetry = retry.Policy{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     30 * time.Second,
+	Multiplier:      2,
+	Jitter:          0.2,
+}
+
+func newInstanceID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func (repository *Repository) changePayload(op changeOp, orderUid string, tracks []string) (string, error) {
+	payload, err := json.Marshal(orderChange{Op: op, OrderUID: orderUid, Origin: repository.instanceID, Tracks: tracks})
+	if err != nil {
+		return "", fmt.Errorf("marshal change: %w", err)
+	}
+	return string(payload), nil
+}
+
+// startListener subscribes to ordersChannel in the background and keeps
+// resubscribing with backoff until Close.
+func (repository *Repository) startListener() {
+	ctx, cancel := context.WithCancel(context.Background())
+	repository.stopListener = cancel
+	repository.listenerDone = make(chan struct{})
+
+	go func() {
+		defer close(repository.listenerDone)
+
+		attempt := 0
+		everSubscribed := false
+		for {
+			subscribed, err := repository.listen(ctx, everSubscribed)
+			if ctx.Err() != nil {
+				return
+			}
+			if subscribed {
+				everSubscribed = true
+				attempt = 0
+			}
+			attempt++
+			log.Printf("Listening to %s is interrupted: %v", ordersChannel, err)
+
+			select {
+			case <-time.After(listenRetry.Backoff(attempt)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// listen takes a connection out of the pool for the lifetime of the
+// subscription and handles notifications until ctx is done or the
+// connection fails. It reports whether the subscription was established.
+func (repository *Repository) listen(ctx context.Context, resubscribe bool) (bool, error) {
+	conn, err := repository.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(ctx, "LISTEN "+ordersChannel); err != nil {
+		return false, err
+	}
+	log.Printf("Listening to %s", ordersChannel)
+
+	// Changes made while the listener was down were missed, so nothing in
+	// the cache can be trusted after a reconnect.
+	if resubscribe {
+		repository.FlushCache()
+	}
+
+	for {
+		notification, err := pgConn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		repository.handleChange(notification.Payload)
+	}
+}
+
+func (repository *Repository) handleChange(payload string) {
+	var change orderChange
+	if err := json.Unmarshal([]byte(payload), &change); err != nil {
+		log.Printf("Invalid %s payload %q: %v", ordersChannel, payload, err)
+		return
+	}
+	if change.Origin == repository.instanceID {
+		return
+	}
+
+	var cached bool
+	repository.generations.bump(change.OrderUID, func() {
+		if repository.negative != nil {
+			repository.negative.Remove(change.OrderUID)
+		}
+		cached = repository.cache.Remove(change.OrderUID)
+	})
+	repository.forgetTracks(change.Tracks)
+
+	if change.Op == opDelete {
+		log.Printf("Order %s is deleted by %s, evicted from cache", change.OrderUID, change.Origin)
+		return
+	}
+
+	// Only orders this instance already caches are worth reloading.
+	if !cached {
+		return
+	}
+	gen := repository.generations.current(change.OrderUID)
+	order, exist, err := repository.selectFromDB(change.OrderUID)
+	if err != nil || !exist {
+		log.Printf("Unable to refresh order %s: exist %v, %v", change.OrderUID, exist, err)
+		return
+	}
+	repository.generations.ifCurrent(change.OrderUID, gen, func() {
+		repository.cacheOrder(order)
+	})
+	log.Printf("Order %s is %s by %s, refreshed in cache", change.OrderUID, change.Op, change.Origin)
+}
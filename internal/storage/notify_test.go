@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"test-task/internal/cache"
+	"test-task/pkg/models"
+)
+
+func newTestRepository() *Repository {
+	return &Repository{
+		cache:      cache.CreateCache(10),
+		negative:   cache.CreateNegativeCache(10, time.Minute),
+		tracks:     cache.CreateTrackCache(10, time.Minute),
+		instanceID: "self",
+	}
+}
+
+func changeFrom(t *testing.T, origin string, op changeOp, orderUid string) string {
+	t.Helper()
+	other := &Repository{instanceID: origin}
+	payload, err := other.changePayload(op, orderUid, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestHandleChange_DeleteEvicts(t *testing.T) {
+	repository := newTestRepository()
+	repository.cache.Add(&models.Order{OrderUID: "order-1"})
+
+	repository.handleChange(changeFrom(t, "other", opDelete, "order-1"))
+
+	if repository.cache.Contains("order-1") {
+		t.Error("Deleted order should be evicted")
+	}
+}
+
+func TestHandleChange_IgnoresOwnChanges(t *testing.T) {
+	repository := newTestRepository()
+	repository.cache.Add(&models.Order{OrderUID: "order-1"})
+
+	repository.handleChange(changeFrom(t, "self", opDelete, "order-1"))
+
+	if !repository.cache.Contains("order-1") {
+		t.Error("Own change should not evict the order")
+	}
+}
+
+func TestHandleChange_InsertClearsNegativeCache(t *testing.T) {
+	repository := newTestRepository()
+	repository.negative.Add("order-1")
+
+	repository.handleChange(changeFrom(t, "other", opInsert, "order-1"))
+
+	if repository.negative.Contains("order-1") {
+		t.Error("Inserted order should not be reported missing")
+	}
+}
+
+func TestHandleChange_InsertForgetsTracks(t *testing.T) {
+	repository := newTestRepository()
+	repository.tracks.Add("WBILMTESTTRACK", nil)
+	repository.tracks.Add("OTHERTRACK", []string{"order-2"})
+
+	other := &Repository{instanceID: "other"}
+	payload, err := other.changePayload(opInsert, "order-1", []string{"WBILMTESTTRACK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository.handleChange(payload)
+
+	if _, found := repository.tracks.Get("WBILMTESTTRACK"); found {
+		t.Error("Track number of the inserted order should be forgotten")
+	}
+	if _, found := repository.tracks.Get("OTHERTRACK"); !found {
+		t.Error("Unrelated track number should stay cached")
+	}
+}
+
+func TestHandleChange_InvalidPayload(t *testing.T) {
+	repository := newTestRepository()
+	repository.cache.Add(&models.Order{OrderUID: "order-1"})
+
+	repository.handleChange("not json")
+
+	if !repository.cache.Contains("order-1") {
+		t.Error("Invalid payload should not change the cache")
+	}
+}
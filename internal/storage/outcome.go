@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"test-task/pkg/models"
+)
+
+// InsertOutcome reports what InsertToDB did with an order.
+type InsertOutcome int
+
+const (
+	OrderInserted InsertOutcome = iota + 1
+	OrderUnchanged
+	OrderUpdated
+)
+
+func (outcome InsertOutcome) String() string {
+	switch outcome {
+	case OrderInserted:
+		return "inserted"
+	case OrderUnchanged:
+		return "unchanged"
+	case OrderUpdated:
+		return "updated"
+	}
+	return fmt.Sprintf("InsertOutcome(%d)", int(outcome))
+}
+
+// contentHash identifies the content of an order as it was received. It is
+// compared against the stored hash to detect redeliveries of the same order.
+func contentHash(order *models.Order) (string, error) {
+	normalized := *order
+	normalized.DateCreated = order.DateCreated.UTC()
+
+	data, err := json.Marshal(&normalized)
+	if err != nil {
+		return "", fmt.Errorf("marshal order %s: %w", order.OrderUID, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"test-task/pkg/models"
+)
+
+func testOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:  "Test Testov",
+			Phone: "+9720000000",
+			City:  "Kiryat Mozkin",
+			Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			Currency:    "USD",
+			Amount:      1817,
+		},
+		Items: []models.Item{
+			{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, TotalPrice: 317},
+		},
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+	}
+}
+
+func TestContentHash_SameContent(t *testing.T) {
+	order := testOrder()
+	moved := testOrder()
+	moved.DateCreated = moved.DateCreated.In(time.FixedZone("MSK", 3*60*60))
+
+	first, err := contentHash(&order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := contentHash(&moved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("Same order in another time zone got a different hash")
+	}
+}
+
+func TestContentHash_ChangedContent(t *testing.T) {
+	order := testOrder()
+	changed := testOrder()
+	changed.Items[0].TotalPrice = 318
+
+	first, err := contentHash(&order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := contentHash(&changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("Changed order got the same hash")
+	}
+}
@@ -13,10 +13,31 @@ const (
 			shardkey,
 			sm_id,
 			date_created,
-			oof_shard
+			oof_shard,
+			content_hash
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
-		);`
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+		)
+		ON CONFLICT (order_uid) DO NOTHING;`
+
+	selectOrderHash = `
+		SELECT content_hash FROM "orders" WHERE order_uid = $1 FOR UPDATE;`
+
+	updateOrder = `
+		UPDATE "orders" SET
+			track_number = $2,
+			entry = $3,
+			locale = $4,
+			internal_signature = $5,
+			customer_id = $6,
+			delivery_service = $7,
+			shardkey = $8,
+			sm_id = $9,
+			date_created = $10,
+			oof_shard = $11,
+			content_hash = $12
+		WHERE order_uid = $1;`
+
 	insertDelivery = `
 		INSERT INTO "deliveries" (
 			order_uid,
@@ -28,8 +49,16 @@ const (
 			region,
 			email
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8 
-		);`
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			name = EXCLUDED.name,
+			phone = EXCLUDED.phone,
+			zip = EXCLUDED.zip,
+			city = EXCLUDED.city,
+			address = EXCLUDED.address,
+			region = EXCLUDED.region,
+			email = EXCLUDED.email;`
 
 	insertPayment = `
 		INSERT INTO "payments" (
@@ -46,7 +75,18 @@ const (
 			custom_fee
 		) VALUES (
 			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
-		);`
+		)
+		ON CONFLICT (order_uid) DO UPDATE SET
+			transaction = EXCLUDED.transaction,
+			request_id = EXCLUDED.request_id,
+			currency = EXCLUDED.currency,
+			provider = EXCLUDED.provider,
+			amount = EXCLUDED.amount,
+			payment_dt = EXCLUDED.payment_dt,
+			bank = EXCLUDED.bank,
+			delivery_cost = EXCLUDED.delivery_cost,
+			goods_total = EXCLUDED.goods_total,
+			custom_fee = EXCLUDED.custom_fee;`
 
 	insertItem = `
 		INSERT INTO "items" (
@@ -63,6 +103,212 @@ const (
 			brand,
 			status
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12 
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
 		)`
+
+	deleteOrder = `
+		DELETE FROM "orders" WHERE order_uid = $1;`
+
+	notifyOrderChange = `
+		SELECT pg_notify($1, $2);`
+
+	deleteItems = `
+		DELETE FROM "items" WHERE order_uid = $1;`
+
+	selectOrderHashes = `
+		SELECT order_uid, content_hash FROM "orders"
+		WHERE order_uid = ANY($1)
+		FOR UPDATE;`
+
+	selectStoredOrderHashes = `
+		SELECT order_uid, COALESCE(content_hash, '') FROM "orders"
+		WHERE order_uid = ANY($1);`
+
+	deleteItemsOfOrders = `
+		DELETE FROM "items" WHERE order_uid = ANY($1);`
+
+	selectOrder = `
+		SELECT
+			order_uid,
+			track_number,
+			entry,
+			locale,
+			internal_signature,
+			customer_id,
+			delivery_service,
+			shardkey,
+			sm_id,
+			date_created,
+			oof_shard
+		FROM "orders" WHERE order_uid = $1;`
+
+	selectDelivery = `
+		SELECT
+			order_uid,
+			name,
+			phone,
+			zip,
+			city,
+			address,
+			region,
+			email
+		FROM "deliveries" WHERE order_uid = $1;`
+
+	selectPayment = `
+		SELECT
+			order_uid,
+			transaction,
+			request_id,
+			currency,
+			provider,
+			amount,
+			payment_dt,
+			bank,
+			delivery_cost,
+			goods_total,
+			custom_fee
+		FROM "payments" WHERE order_uid = $1;`
+
+	selectItems = `
+		SELECT
+			id,
+			order_uid,
+			chrt_id,
+			track_number,
+			price,
+			rid,
+			name,
+			sale,
+			size,
+			total_price,
+			nm_id,
+			brand,
+			status
+		FROM "items" WHERE order_uid = $1 ORDER BY id;`
+
+	selectRecentOrderUIDs = `
+		SELECT order_uid FROM "orders" ORDER BY date_created DESC, order_uid DESC LIMIT $1;`
+
+	selectOrderUIDsByTrack = `
+		SELECT order_uid FROM "orders"
+		WHERE order_uid IN (
+			SELECT order_uid FROM "orders" WHERE track_number = $1
+			UNION
+			SELECT order_uid FROM "items" WHERE track_number = $1
+		)
+		ORDER BY date_created DESC, order_uid DESC
+		LIMIT $2;`
+
+	selectCustomerSummary = `
+		SELECT
+			GROUPING(p.currency) = 1,
+			p.currency,
+			COUNT(*),
+			SUM(p.amount)::BIGINT,
+			MIN(o.date_created),
+			MAX(o.date_created)
+		FROM "orders" o
+		LEFT JOIN "payments" p ON p.order_uid = o.order_uid
+		WHERE o.customer_id = $1
+		GROUP BY GROUPING SETS ((), (p.currency));`
+
+	// searchOrders ranks orders matching $1 as words in the delivery or item
+	// search vectors, or $2 as a LIKE pattern on the phone or email. A NULL
+	// $2 skips the substring search.
+	searchOrders = `
+		WITH query AS (
+			SELECT websearch_to_tsquery('simple', $1) AS ts
+		), matches AS (
+			SELECT d.order_uid, ts_rank(d.search_vector, q.ts) AS rank, 'delivery' AS source
+			FROM "deliveries" d, query q WHERE d.search_vector @@ q.ts
+			UNION ALL
+			SELECT i.order_uid, ts_rank(i.search_vector, q.ts), 'item'
+			FROM "items" i, query q WHERE i.search_vector @@ q.ts
+			UNION ALL
+			SELECT order_uid, 1, 'phone' FROM "deliveries" WHERE phone ILIKE $2
+			UNION ALL
+			SELECT order_uid, 1, 'email' FROM "deliveries" WHERE email ILIKE $2
+		), ranked AS (
+			SELECT order_uid, MAX(rank) AS rank, array_agg(DISTINCT source ORDER BY source) AS sources
+			FROM matches GROUP BY order_uid
+		)
+		SELECT
+			o.order_uid,
+			o.track_number,
+			o.customer_id,
+			o.date_created,
+			COALESCE(d.name, ''),
+			COALESCE(d.city, ''),
+			COALESCE(p.amount, 0),
+			COALESCE(p.currency, ''),
+			r.rank,
+			r.sources
+		FROM ranked r
+		JOIN "orders" o ON o.order_uid = r.order_uid
+		LEFT JOIN "deliveries" d ON d.order_uid = o.order_uid
+		LEFT JOIN "payments" p ON p.order_uid = o.order_uid
+		ORDER BY r.rank DESC, o.date_created DESC, o.order_uid DESC
+		LIMIT $3;`
+
+	selectAnyOrderUIDs = `
+		SELECT order_uid FROM "orders" LIMIT $1;`
+
+	selectOrdersByUIDs = `
+		SELECT
+			order_uid,
+			track_number,
+			entry,
+			locale,
+			internal_signature,
+			customer_id,
+			delivery_service,
+			shardkey,
+			sm_id,
+			date_created,
+			oof_shard
+		FROM "orders" WHERE order_uid = ANY($1);`
+
+	selectDeliveriesByUIDs = `
+		SELECT
+			order_uid,
+			name,
+			phone,
+			zip,
+			city,
+			address,
+			region,
+			email
+		FROM "deliveries" WHERE order_uid = ANY($1);`
+
+	selectPaymentsByUIDs = `
+		SELECT
+			order_uid,
+			transaction,
+			request_id,
+			currency,
+			provider,
+			amount,
+			payment_dt,
+			bank,
+			delivery_cost,
+			goods_total,
+			custom_fee
+		FROM "payments" WHERE order_uid = ANY($1);`
+
+	selectItemsByUIDs = `
+		SELECT
+			id,
+			order_uid,
+			chrt_id,
+			track_number,
+			price,
+			rid,
+			name,
+			sale,
+			size,
+			total_price,
+			nm_id,
+			brand,
+			status
+		FROM "items" WHERE order_uid = ANY($1) ORDER BY id;`
 )
@@ -2,24 +2,49 @@ package storage
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"time"
 
 	"test-task/internal/cache"
 	"test-task/pkg/models"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/sync/singleflight"
 )
 
 type Repository struct {
-	pool  *pgxpool.Pool
-	cache cache.Cache
+	pool         *pgxpool.Pool
+	cache        *cache.Cache
+	cacheOptions CacheOptions
+	// negative is nil when negative caching is disabled.
+	negative *cache.NegativeCache
+	// tracks is nil when caching of track number lookups is disabled.
+	tracks *cache.TrackCache
+	// loads deduplicates concurrent database lookups of the same order.
+	loads singleflight.Group
+	// load reads one order from the database; it is selectFromDB except in
+	// tests.
+	load func(orderUid string) (models.Order, bool, error)
+	// generations keeps loads that race with writes out of the caches.
+	generations generations
+
+	// instanceID tags change notifications sent by this repository.
+	instanceID   string
+	stopListener context.CancelFunc
+	listenerDone chan struct{}
 }
 
-const cacheCapacity = 1
+type loadResult struct {
+	order models.Order
+	exist bool
+}
+
+// acquireTimeout bounds waiting for a free connection, so an exhausted pool
+// surfaces as a transient error instead of blocking the caller forever.
+const acquireTimeout = 5 * time.Second
 
-func (repository *Repository) InitRepository(connStr string) error {
+func (repository *Repository) InitRepository(connStr string, cacheOptions CacheOptions) error {
 
 	config, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
@@ -35,115 +60,136 @@ func (repository *Repository) InitRepository(connStr string) error {
 		return err
 	}
 
-	repository.cache = *cache.CreateCache(cacheCapacity)
-
-	orders, err := repository.GetOrders(cacheCapacity)
+	repository.load = repository.selectFromDB
+	repository.cacheOptions = cacheOptions
+	repository.cache, err = cache.CreateCacheWithOptions(cache.Options{
+		Capacity: cacheOptions.Capacity,
+		MaxBytes: cacheOptions.MaxBytes,
+		TTL:      cacheOptions.TTL,
+		Policy:   cacheOptions.Policy,
+	})
 	if err != nil {
-		log.Printf("Unable to init cache: %v", err)
+		log.Printf("Unable to create cache: %v", err)
+		repository.pool.Close()
 		return err
 	}
-	for i := 0; i < len(orders); i++ {
-		repository.cache.Add(&orders[i])
+	if cacheOptions.NegativeTTL > 0 {
+		repository.negative = cache.CreateNegativeCache(cacheOptions.NegativeCapacity, cacheOptions.NegativeTTL)
+	}
+	if cacheOptions.TrackTTL > 0 {
+		repository.tracks = cache.CreateTrackCache(cacheOptions.TrackCapacity, cacheOptions.TrackTTL)
+	}
+
+	repository.instanceID = newInstanceID()
+	repository.startListener()
+
+	if repository.loadCacheSnapshot() {
+		return nil
+	}
+	if err := repository.WarmUpCache(); err != nil {
+		log.Printf("Unable to init cache: %v", err)
+		repository.stopListener()
+		<-repository.listenerDone
+		repository.pool.Close()
+		return err
 	}
 
 	return nil
 
 }
 
-
-func (repository *Repository) GetOrders(quantity int) ([]models.Order, error) {
+// InsertToDB stores the order idempotently. A redelivered order with the same
+// content is a no-op; changed content replaces the stored order, including
+// the whole item set. Everything happens in one transaction.
+func (repository *Repository) InsertToDB(order *models.Order) (InsertOutcome, error) {
 	ctx := context.Background()
 
-	conn, err := repository.pool.Acquire(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("acquire connection: %w", err)
-	}
-	defer conn.Release()
-
-	rows, err := conn.Query(ctx, `SELECT order_uid FROM orders LIMIT $1`, quantity)
+	hash, err := contentHash(order)
 	if err != nil {
-		return nil, fmt.Errorf("query: %w", err)
-	}
-	defer rows.Close()
-
-	uidsSet := make(map[string]struct{})
-	for rows.Next() {
-		var uid string
-		if err := rows.Scan(&uid); err != nil {
-			return nil, fmt.Errorf("scan uid: %w", err)
-		}
-		uidsSet[uid] = struct{}{}
-	}
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iteration: %w", err)
-	}
-
-	var orders []models.Order
-	for uid := range uidsSet {
-		order, found, err := repository.FindOrderById(uid)
-		if err != nil {
-			log.Printf("error finding %s: %v", uid, err)
-			continue
-		}
-		if !found {
-			log.Printf("order not found: %s", uid)
-			continue
-		}
-		orders = append(orders, order)
+		return 0, err
 	}
 
-	return orders, nil
-}
-
-func (repository *Repository) InsertToDB(order *models.Order) error {
-	conn, err := repository.pool.Acquire(context.Background())
+	acquireCtx, cancel := context.WithTimeout(ctx, acquireTimeout)
+	defer cancel()
+	conn, err := repository.pool.Acquire(acquireCtx)
 	if err != nil {
 		log.Printf("Unable to get connection from the Pool: %v", err)
-		return err
+		return 0, err
 	}
 	defer conn.Release()
 
-	tx, err := conn.Begin(context.Background())
+	tx, err := conn.Begin(ctx)
 	if err != nil {
 		log.Printf("Error starting transaction: %v", err)
-		return err
+		return 0, err
 	}
-	defer tx.Rollback(context.Background())
+	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(context.Background(), insertOrder,
+	outcome := OrderInserted
+	tag, err := tx.Exec(ctx, insertOrder,
 		order.OrderUID, order.TrackNumber, order.Entry,
 		order.Locale, order.InternalSignature, order.CustomerID,
 		order.DeliveryService, order.Shardkey, order.SmID,
-		order.DateCreated, order.OofShard)
+		order.DateCreated, order.OofShard, hash)
 	if err != nil {
 		log.Printf("Error inserting order: %v", err)
-		return err
+		return 0, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		var storedHash *string
+		err = tx.QueryRow(ctx, selectOrderHash, order.OrderUID).Scan(&storedHash)
+		if err != nil {
+			log.Printf("Error reading stored order: %v", err)
+			return 0, err
+		}
+		if storedHash != nil && *storedHash == hash {
+			log.Printf("Order %s is already stored", order.OrderUID)
+			return OrderUnchanged, nil
+		}
+
+		outcome = OrderUpdated
+		_, err = tx.Exec(ctx, updateOrder,
+			order.OrderUID, order.TrackNumber, order.Entry,
+			order.Locale, order.InternalSignature, order.CustomerID,
+			order.DeliveryService, order.Shardkey, order.SmID,
+			order.DateCreated, order.OofShard, hash)
+		if err != nil {
+			log.Printf("Error updating order: %v", err)
+			return 0, err
+		}
+
+		_, err = tx.Exec(ctx, deleteItems, order.OrderUID)
+		if err != nil {
+			log.Printf("Error deleting items: %v", err)
+			return 0, err
+		}
 	}
 
 	delivery := &order.Delivery
-	_, err = tx.Exec(context.Background(), insertDelivery,
+	_, err = tx.Exec(ctx, insertDelivery,
 		order.OrderUID, delivery.Name, delivery.Phone,
 		delivery.Zip, delivery.City, delivery.Address,
 		delivery.Region, delivery.Email)
 	if err != nil {
 		log.Printf("Error inserting delivery: %v", err)
-		return err
+		return 0, err
 	}
 
 	payment := &order.Payment
-	_, err = tx.Exec(context.Background(), insertPayment,
+	_, err = tx.Exec(ctx, insertPayment,
 		order.OrderUID, payment.Transaction, payment.RequestID,
 		payment.Currency, payment.Provider, payment.Amount,
 		payment.PaymentDt, payment.Bank, payment.DeliveryCost,
 		payment.GoodsTotal, payment.CustomFee)
 	if err != nil {
 		log.Printf("Error inserting payment: %v", err)
-		return err
+		return 0, err
 	}
 
 	for i := 0; i < len(order.Items); i++ {
 		item := &order.Items[i]
-		_, err = tx.Exec(context.Background(), insertItem,
+		_, err = tx.Exec(ctx, insertItem,
 			order.OrderUID, item.ChrtID, item.TrackNumber,
 			item.Price, item.Rid, item.Name, item.Sale,
 			item.Size, item.TotalPrice, item.NmID,
@@ -151,19 +197,38 @@ func (repository *Repository) InsertToDB(order *models.Order) error {
 		)
 		if err != nil {
 			log.Printf("Error inserting items: %v", err)
-			return err
+			return 0, err
 		}
 	}
 
-	err = tx.Commit(context.Background())
+	op := opInsert
+	if outcome == OrderUpdated {
+		op = opUpdate
+	}
+	if err := repository.notifyChange(ctx, tx, op, order.OrderUID, orderTracks(order)); err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
-		log.Fatalf("Error committing transaction: %v", err)
-		return err
+		log.Printf("Error committing transaction: %v", err)
+		return 0, err
 	}
 
-	log.Println("Insert is completed")
-	return nil
+	log.Printf("Order %s is %v", order.OrderUID, outcome)
+	repository.cacheWritten(order)
+	return outcome, nil
+}
 
+// cacheWritten brings the caches up to date with a committed order.
+func (repository *Repository) cacheWritten(order *models.Order) {
+	repository.generations.bump(order.OrderUID, func() {
+		repository.cacheOrder(*order)
+		if repository.negative != nil {
+			repository.negative.Remove(order.OrderUID)
+		}
+	})
+	repository.forgetTracks(orderTracks(order))
 }
 
 func (repository *Repository) FindOrderById(orderUid string) (order models.Order, exist bool, err error) {
@@ -172,8 +237,44 @@ func (repository *Repository) FindOrderById(orderUid string) (order models.Order
 		log.Printf("Have found in the cache")
 		return *cacheOrder, true, nil
 	}
-	log.Printf("Have found in the DB")
-	return repository.selectFromDB(orderUid)
+	if repository.negative != nil && repository.negative.Contains(orderUid) {
+		log.Printf("Have found in the negative cache")
+		return order, false, nil
+	}
+
+	// Concurrent misses for the same order wait for a single load.
+	result, err, shared := repository.loads.Do(orderUid, func() (any, error) {
+		gen := repository.generations.current(orderUid)
+		order, exist, err := repository.load(orderUid)
+		if err != nil {
+			return loadResult{}, err
+		}
+		// An order written while it was being read is already cached, or
+		// known to exist, and must not be replaced with the older result.
+		repository.generations.ifCurrent(orderUid, gen, func() {
+			if exist {
+				repository.cacheOrder(order)
+			} else if repository.negative != nil {
+				repository.negative.Add(orderUid)
+			}
+		})
+		return loadResult{order: order, exist: exist}, nil
+	})
+	if err != nil {
+		return order, false, err
+	}
+	loaded := result.(loadResult)
+	if loaded.exist {
+		log.Printf("Have found in the DB, shared: %v", shared)
+	}
+	return loaded.order, loaded.exist, nil
+}
+
+// cacheOrder stores a private copy, so later changes made by the caller to
+// its order do not leak into the cache.
+func (repository *Repository) cacheOrder(order models.Order) {
+	order.Items = append([]models.Item(nil), order.Items...)
+	repository.cache.Add(&order)
 }
 
 func (repository *Repository) selectFromDB(orderUid string) (order models.Order, exist bool, err error) {
@@ -182,16 +283,18 @@ func (repository *Repository) selectFromDB(orderUid string) (order models.Order,
 	conn, err := repository.pool.Acquire(context.Background())
 	if err != nil {
 		log.Printf("Unable to get connection from the Pool: %v", err)
+		return
 	}
 	defer conn.Release()
 
 	tx, err := conn.BeginTx(context.Background(), pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
 		log.Printf("Error starting transaction: %v", err)
+		return
 	}
 	defer tx.Rollback(context.Background())
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM orders WHERE order_uid = $1", orderUid).Scan(
+	err = tx.QueryRow(context.Background(), selectOrder, orderUid).Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry,
 		&order.Locale, &order.InternalSignature, &order.CustomerID,
 		&order.DeliveryService, &order.Shardkey, &order.SmID,
@@ -208,7 +311,7 @@ func (repository *Repository) selectFromDB(orderUid string) (order models.Order,
 		return
 	}
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM deliveries WHERE order_uid = $1", orderUid).Scan(
+	err = tx.QueryRow(context.Background(), selectDelivery, orderUid).Scan(
 		&order.Delivery.OrderUID, &order.Delivery.Name, &order.Delivery.Phone,
 		&order.Delivery.Zip, &order.Delivery.City, &order.Delivery.Address,
 		&order.Delivery.Region, &order.Delivery.Email,
@@ -218,7 +321,7 @@ func (repository *Repository) selectFromDB(orderUid string) (order models.Order,
 		return
 	}
 
-	err = tx.QueryRow(context.Background(), "SELECT * FROM payments WHERE order_uid = $1", orderUid).Scan(
+	err = tx.QueryRow(context.Background(), selectPayment, orderUid).Scan(
 		&order.Payment.OrderUID, &order.Payment.Transaction, &order.Payment.RequestID,
 		&order.Payment.Currency, &order.Payment.Provider, &order.Payment.Amount,
 		&order.Payment.PaymentDt, &order.Payment.Bank, &order.Payment.DeliveryCost,
@@ -229,7 +332,7 @@ func (repository *Repository) selectFromDB(orderUid string) (order models.Order,
 		return
 	}
 
-	rows, err := tx.Query(context.Background(), "SELECT * FROM items WHERE order_uid = $1", orderUid)
+	rows, err := tx.Query(context.Background(), selectItems, orderUid)
 	if err != nil {
 		log.Printf("Query of items is failed: %v", err)
 		return
@@ -245,6 +348,59 @@ func (repository *Repository) selectFromDB(orderUid string) (order models.Order,
 	return
 }
 
+// DeleteOrder removes the order with its delivery, payment and items and
+// reports whether it existed.
+func (repository *Repository) DeleteOrder(orderUid string) (bool, error) {
+	ctx := context.Background()
+
+	tx, err := repository.pool.Begin(ctx)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, deleteOrder, orderUid)
+	if err != nil {
+		log.Printf("Error deleting order: %v", err)
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+	if err := repository.notifyChange(ctx, tx, opDelete, orderUid, nil); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("Error committing transaction: %v", err)
+		return false, err
+	}
+
+	repository.generations.bump(orderUid, func() {
+		repository.cache.Remove(orderUid)
+	})
+	log.Printf("Order %s is deleted", orderUid)
+	return true, nil
+}
+
+// notifyChange queues a notification that Postgres delivers to the other
+// instances only if tx commits.
+func (repository *Repository) notifyChange(ctx context.Context, tx pgx.Tx, op changeOp, orderUid string, tracks []string) error {
+	payload, err := repository.changePayload(op, orderUid, tracks)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, notifyOrderChange, ordersChannel, payload); err != nil {
+		log.Printf("Error notifying about order change: %v", err)
+		return err
+	}
+	return nil
+}
+
 func (repository *Repository) Close() {
+	repository.stopListener()
+	<-repository.listenerDone
+	repository.saveCacheSnapshot()
 	repository.pool.Close()
 }
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task/pkg/models"
+)
+
+func TestFindOrderById_ConcurrentMissesShareLoad(t *testing.T) {
+	const callers = 20
+
+	repository := newTestRepository()
+	var loads atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	repository.load = func(orderUid string) (models.Order, bool, error) {
+		if loads.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		order := testOrder()
+		order.OrderUID = orderUid
+		return order, true, nil
+	}
+
+	orders := make([]models.Order, callers)
+	errs := make([]error, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var exist bool
+			orders[i], exist, errs[i] = repository.FindOrderById("order-1")
+			if !exist && errs[i] == nil {
+				t.Errorf("Caller %d did not find the order", i)
+			}
+		}()
+	}
+
+	// The first miss is loading; give the others time to join it.
+	<-started
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("Order is loaded %d times, wanted once", got)
+	}
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("Caller %d failed: %v", i, errs[i])
+		}
+		if !reflect.DeepEqual(orders[i], orders[0]) {
+			t.Errorf("Caller %d got %+v, wanted %+v", i, orders[i], orders[0])
+		}
+	}
+	if orders[0].OrderUID != "order-1" {
+		t.Errorf("Got order %s, wanted order-1", orders[0].OrderUID)
+	}
+	if !repository.cache.Contains("order-1") {
+		t.Error("Loaded order should be cached")
+	}
+}
+
+func TestFindOrderById_ReadThrough(t *testing.T) {
+	repository := newTestRepository()
+	var loads int
+	repository.load = func(orderUid string) (models.Order, bool, error) {
+		loads++
+		order := testOrder()
+		order.OrderUID = orderUid
+		return order, true, nil
+	}
+
+	for range 2 {
+		if _, exist, err := repository.FindOrderById("order-1"); err != nil || !exist {
+			t.Fatalf("Order is not found: exist %v, %v", exist, err)
+		}
+	}
+
+	if !repository.IsCached("order-1") {
+		t.Error("Order read from the database should be cached")
+	}
+	if loads != 1 {
+		t.Errorf("Order is loaded %d times, wanted once", loads)
+	}
+}
+
+func TestFindOrderById_MissRacingInsert(t *testing.T) {
+	repository := newTestRepository()
+	inserted := testOrder()
+	inserted.OrderUID = "order-1"
+	// The order is committed and cached while the miss is reading the
+	// database, after the read saw no order.
+	repository.load = func(orderUid string) (models.Order, bool, error) {
+		repository.cacheWritten(&inserted)
+		return models.Order{}, false, nil
+	}
+
+	if _, exist, err := repository.FindOrderById("order-1"); err != nil || exist {
+		t.Fatalf("Racing miss should report what it read: exist %v, %v", exist, err)
+	}
+
+	if repository.negative.Contains("order-1") {
+		t.Error("Inserted order should not be cached as missing")
+	}
+	if _, exist, _ := repository.FindOrderById("order-1"); !exist {
+		t.Error("Inserted order should be found")
+	}
+}
+
+func TestFindOrderById_StaleLoadKeepsNewerCopy(t *testing.T) {
+	repository := newTestRepository()
+	stale := testOrder()
+	stale.OrderUID = "order-1"
+	updated := stale
+	updated.TrackNumber = "UPDATED"
+	repository.load = func(orderUid string) (models.Order, bool, error) {
+		repository.cacheWritten(&updated)
+		return stale, true, nil
+	}
+
+	if _, _, err := repository.FindOrderById("order-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, exist, _ := repository.cache.Get("order-1")
+	if !exist || cached.TrackNumber != "UPDATED" {
+		t.Errorf("Stale load should not replace the written order, cached %+v", cached)
+	}
+}
+
+func TestFindOrderById_MissWithoutWritesIsCached(t *testing.T) {
+	repository := newTestRepository()
+	repository.load = func(orderUid string) (models.Order, bool, error) {
+		return models.Order{}, false, nil
+	}
+
+	repository.FindOrderById("order-1")
+
+	if !repository.negative.Contains("order-1") {
+		t.Error("Missing order should be cached as missing")
+	}
+}
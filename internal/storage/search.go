@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// minSubstringLength is the shortest query also looked up as a substring of
+// phones and emails; shorter ones would match nearly every row.
+const minSubstringLength = 3
+
+// Where a search query matched, see OrderSummary.MatchedIn.
+const (
+	MatchDelivery = "delivery"
+	MatchItem     = "item"
+	MatchPhone    = "phone"
+	MatchEmail    = "email"
+)
+
+// OrderSummary is a search result: enough to recognise the order without
+// loading it in full.
+type OrderSummary struct {
+	OrderUID      string       `json:"order_uid"`
+	TrackNumber   string       `json:"track_number"`
+	CustomerID    string       `json:"customer_id"`
+	DateCreated   time.Time    `json:"date_created"`
+	RecipientName string       `json:"recipient_name"`
+	City          string       `json:"city"`
+	Amount        models.Money `json:"amount"`
+	Currency      string       `json:"currency"`
+	Rank          float64      `json:"rank"`
+	// MatchedIn lists where the query matched, in alphabetical order.
+	MatchedIn []string `json:"matched_in"`
+}
+
+type SearchQuery struct {
+	Text  string
+	Limit int
+}
+
+// SearchOrders finds orders by words of the recipient name, city, region or
+// address and of item names and brands, and by a substring of the phone or
+// email. Results go from the best match; a phone or email match ranks above
+// word matches.
+func (repository *Repository) SearchOrders(query SearchQuery) ([]OrderSummary, error) {
+	var pattern *string
+	if len([]rune(query.Text)) >= minSubstringLength {
+		like := "%" + likeEscaper.Replace(query.Text) + "%"
+		pattern = &like
+	}
+
+	rows, err := repository.pool.Query(context.Background(), searchOrders, query.Text, pattern, query.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("search orders: %w", err)
+	}
+	summaries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (OrderSummary, error) {
+		var summary OrderSummary
+		err := row.Scan(
+			&summary.OrderUID, &summary.TrackNumber, &summary.CustomerID,
+			&summary.DateCreated, &summary.RecipientName, &summary.City,
+			&summary.Amount, &summary.Currency, &summary.Rank, &summary.MatchedIn,
+		)
+		return summary, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect search results: %w", err)
+	}
+	return summaries, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchWords splits text into lowercase words the way the 'simple' text
+// search configuration roughly does.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsWords reports whether every word of query is a word of text.
+func containsWords(text string, query []string) bool {
+	words := searchWords(text)
+	for _, word := range query {
+		if !slices.Contains(words, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// summarize is the in-memory version of searchOrders; it ranks a match by
+// the number of places it was found in.
+func summarize(order *models.Order, query string) (OrderSummary, bool) {
+	words := searchWords(query)
+	var matched []string
+
+	delivery := &order.Delivery
+	if len(words) > 0 && containsWords(delivery.Name+" "+delivery.City+" "+delivery.Region+" "+delivery.Address, words) {
+		matched = append(matched, MatchDelivery)
+	}
+	for i := range order.Items {
+		if len(words) > 0 && containsWords(order.Items[i].Name+" "+order.Items[i].Brand, words) {
+			matched = append(matched, MatchItem)
+			break
+		}
+	}
+	if len([]rune(query)) >= minSubstringLength {
+		lower := strings.ToLower(query)
+		if strings.Contains(strings.ToLower(delivery.Phone), lower) {
+			matched = append(matched, MatchPhone)
+		}
+		if strings.Contains(strings.ToLower(delivery.Email), lower) {
+			matched = append(matched, MatchEmail)
+		}
+	}
+	if len(matched) == 0 {
+		return OrderSummary{}, false
+	}
+	sort.Strings(matched)
+
+	return OrderSummary{
+		OrderUID:      order.OrderUID,
+		TrackNumber:   order.TrackNumber,
+		CustomerID:    order.CustomerID,
+		DateCreated:   order.DateCreated,
+		RecipientName: delivery.Name,
+		City:          delivery.City,
+		Amount:        order.Payment.Amount,
+		Currency:      order.Payment.Currency,
+		Rank:          float64(len(matched)),
+		MatchedIn:     matched,
+	}, true
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"test-task/internal/cache"
+	"test-task/pkg/models"
+)
+
+// OrderStore is the order storage used by the HTTP handlers and the Kafka
+// consumer. Repository implements it on top of Postgres, MemoryStore keeps
+// everything in memory.
+type OrderStore interface {
+	// InsertToDB stores the order idempotently and reports what it did.
+	InsertToDB(order *models.Order) (InsertOutcome, error)
+	// InsertBatch stores the orders as InsertToDB would one after another and
+	// returns an outcome per order.
+	InsertBatch(orders []*models.Order) ([]InsertOutcome, error)
+	FindOrderById(orderUid string) (order models.Order, exist bool, err error)
+	// FindOrdersByTrack returns the orders that have the track number
+	// themselves or on one of their items, newest first.
+	FindOrdersByTrack(trackNumber string) ([]models.Order, error)
+	// ListOrders returns orders from the newest to the oldest by date_created.
+	ListOrders(query ListQuery) ([]models.Order, error)
+	// SearchOrders finds orders by delivery and item details, see
+	// Repository.SearchOrders.
+	SearchOrders(query SearchQuery) ([]OrderSummary, error)
+	// CustomerSummary aggregates every order of the customer.
+	CustomerSummary(customerID string) (CustomerSummary, error)
+	// DeleteOrder removes the order and reports whether it existed.
+	DeleteOrder(orderUid string) (bool, error)
+	Close()
+}
+
+var (
+	_ OrderStore = (*Repository)(nil)
+	_ OrderStore = (*MemoryStore)(nil)
+	_ CacheAdmin = (*Repository)(nil)
+)
+
+// CacheAdmin is implemented by stores that keep an order cache.
+type CacheAdmin interface {
+	CacheStats() cache.Stats
+	IsCached(orderUid string) bool
+	EvictFromCache(orderUid string) bool
+	FlushCache()
+	RewarmCache() error
+}
@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task/internal/migrations"
+	"test-task/pkg/models"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestMemoryStore(t *testing.T) {
+	testOrderStore(t, func(t *testing.T) OrderStore {
+		return NewMemoryStore()
+	})
+}
+
+// TestRepository runs the conformance suite against Postgres. It needs
+// TEST_DATABASE_URL pointing to a disposable database; the schema is
+// migrated up and every table is truncated before each test.
+func TestRepository(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	migrateTestDatabase(t, connStr)
+
+	testOrderStore(t, func(t *testing.T) OrderStore {
+		repository := &Repository{}
+		if err := repository.InitRepository(connStr, CacheOptions{Capacity: 10, TrackTTL: time.Minute, TrackCapacity: 10}); err != nil {
+			t.Fatalf("Failed to init repository: %v", err)
+		}
+		if _, err := repository.pool.Exec(context.Background(), `TRUNCATE "orders" CASCADE`); err != nil {
+			t.Fatalf("Failed to truncate orders: %v", err)
+		}
+		repository.FlushCache()
+		return repository
+	})
+}
+
+func migrateTestDatabase(t *testing.T, connStr string) {
+	ctx := context.Background()
+	migrator, err := migrations.Connect(ctx, connStr)
+	if err != nil {
+		t.Fatalf("Failed to connect to test database: %v", err)
+	}
+	defer migrator.Close(ctx)
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Failed to migrate test database: %v", err)
+	}
+}
+
+// testOrderStore is the behaviour every OrderStore implementation shares.
+func testOrderStore(t *testing.T, newStore func(t *testing.T) OrderStore) {
+	tests := map[string]func(t *testing.T, store OrderStore){
+		"InsertAndFind":       testInsertAndFind,
+		"FindMissing":         testFindMissing,
+		"DuplicateIsNoop":     testDuplicateIsNoop,
+		"ChangedIsUpdated":    testChangedIsUpdated,
+		"ListPagination":      testListPagination,
+		"ListFilters":         testListFilters,
+		"FindOrdersByTrack":   testFindOrdersByTrack,
+		"CustomerSummary":     testCustomerSummary,
+		"SearchOrders":        testSearchOrders,
+		"DeleteOrder":         testDeleteOrder,
+		"DeleteMissingOrder":  testDeleteMissingOrder,
+		"ReturnsPrivateCopy":  testReturnsPrivateCopy,
+		"InsertAfterDeletion": testInsertAfterDeletion,
+		"InsertBatch":         testInsertBatch,
+		"WriteThrough":        testWriteThrough,
+		"ReadThrough":         testReadThrough,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			defer store.Close()
+			test(t, store)
+		})
+	}
+}
+
+func orderAt(orderUid string, created time.Time) models.Order {
+	order := testOrder()
+	order.OrderUID = orderUid
+	order.DateCreated = created
+	return order
+}
+
+func insert(t *testing.T, store OrderStore, order models.Order, want InsertOutcome) {
+	t.Helper()
+	outcome, err := store.InsertToDB(&order)
+	if err != nil {
+		t.Fatalf("Failed to insert %s: %v", order.OrderUID, err)
+	}
+	if outcome != want {
+		t.Fatalf("Insert of %s is %v, wanted %v", order.OrderUID, outcome, want)
+	}
+}
+
+func assertSameOrder(t *testing.T, got, want *models.Order) {
+	t.Helper()
+	gotHash, err := contentHash(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHash, err := contentHash(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotHash != wantHash {
+		t.Errorf("Got order %+v, wanted %+v", *got, *want)
+	}
+}
+
+func testInsertAndFind(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+
+	found, exist, err := store.FindOrderById(order.OrderUID)
+	if err != nil || !exist {
+		t.Fatalf("Inserted order is not found: exist %v, %v", exist, err)
+	}
+	assertSameOrder(t, &found, &order)
+	if found.Delivery.OrderUID != order.OrderUID || found.Items[0].OrderUID != order.OrderUID {
+		t.Error("Nested records should reference the order")
+	}
+}
+
+// cacheAdmin skips the test for stores that keep no cache.
+func cacheAdmin(t *testing.T, store OrderStore) CacheAdmin {
+	t.Helper()
+	admin, ok := store.(CacheAdmin)
+	if !ok {
+		t.Skip("Store keeps no cache")
+	}
+	return admin
+}
+
+func testWriteThrough(t *testing.T, store OrderStore) {
+	admin := cacheAdmin(t, store)
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+
+	if !admin.IsCached(order.OrderUID) {
+		t.Error("Inserted order should be cached")
+	}
+}
+
+func testReadThrough(t *testing.T, store OrderStore) {
+	admin := cacheAdmin(t, store)
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+	if !admin.EvictFromCache(order.OrderUID) {
+		t.Fatal("Inserted order is not evicted")
+	}
+
+	found, exist, err := store.FindOrderById(order.OrderUID)
+	if err != nil || !exist {
+		t.Fatalf("Evicted order is not found in the database: exist %v, %v", exist, err)
+	}
+	assertSameOrder(t, &found, &order)
+	if !admin.IsCached(order.OrderUID) {
+		t.Error("Order read from the database should be cached")
+	}
+}
+
+func testFindMissing(t *testing.T, store OrderStore) {
+	_, exist, err := store.FindOrderById("missing")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if exist {
+		t.Error("Missing order is found")
+	}
+}
+
+func testDuplicateIsNoop(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+	insert(t, store, order, OrderUnchanged)
+}
+
+func testChangedIsUpdated(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+
+	changed := testOrder()
+	changed.Delivery.City = "Tel Aviv"
+	changed.Items = []models.Item{
+		{ChrtID: 1, TrackNumber: "WBILMTESTTRACK", Price: 100, Name: "Brush", Size: "0", TotalPrice: 100},
+		{ChrtID: 2, TrackNumber: "WBILMTESTTRACK", Price: 217, Name: "Comb", Size: "0", TotalPrice: 217},
+	}
+	insert(t, store, changed, OrderUpdated)
+
+	found, _, err := store.FindOrderById(order.OrderUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSameOrder(t, &found, &changed)
+	if len(found.Items) != 2 {
+		t.Errorf("Item set should be replaced, got %d items", len(found.Items))
+	}
+}
+
+func testListPagination(t *testing.T, store OrderStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// order-b and order-c share a timestamp, order_uid breaks the tie.
+	insert(t, store, orderAt("order-a", base), OrderInserted)
+	insert(t, store, orderAt("order-b", base.Add(time.Hour)), OrderInserted)
+	insert(t, store, orderAt("order-c", base.Add(time.Hour)), OrderInserted)
+	insert(t, store, orderAt("order-d", base.Add(2*time.Hour)), OrderInserted)
+
+	var uids []string
+	query := ListQuery{Limit: 3}
+	for page := 0; page < 3; page++ {
+		orders, err := store.ListOrders(query)
+		if err != nil {
+			t.Fatalf("Failed to list orders: %v", err)
+		}
+		for _, order := range orders {
+			uids = append(uids, order.OrderUID)
+		}
+		if len(orders) < query.Limit {
+			break
+		}
+		query.After = CursorOf(&orders[len(orders)-1])
+	}
+
+	want := []string{"order-d", "order-c", "order-b", "order-a"}
+	if fmt.Sprint(uids) != fmt.Sprint(want) {
+		t.Errorf("Listed %v, wanted %v", uids, want)
+	}
+}
+
+func testDeleteOrder(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+
+	deleted, err := store.DeleteOrder(order.OrderUID)
+	if err != nil || !deleted {
+		t.Fatalf("Failed to delete order: deleted %v, %v", deleted, err)
+	}
+	if _, exist, _ := store.FindOrderById(order.OrderUID); exist {
+		t.Error("Deleted order is still found")
+	}
+}
+
+func testDeleteMissingOrder(t *testing.T, store OrderStore) {
+	deleted, err := store.DeleteOrder("missing")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if deleted {
+		t.Error("Missing order reported as deleted")
+	}
+}
+
+func testReturnsPrivateCopy(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+
+	found, _, _ := store.FindOrderById(order.OrderUID)
+	found.Items[0].Name = "changed by caller"
+
+	again, _, _ := store.FindOrderById(order.OrderUID)
+	if again.Items[0].Name == "changed by caller" {
+		t.Error("Changes to a returned order leak into the store")
+	}
+}
+
+func testInsertAfterDeletion(t *testing.T, store OrderStore) {
+	order := testOrder()
+	insert(t, store, order, OrderInserted)
+	if _, err := store.DeleteOrder(order.OrderUID); err != nil {
+		t.Fatal(err)
+	}
+	insert(t, store, order, OrderInserted)
+}
+
+func testInsertBatch(t *testing.T, store OrderStore) {
+	stored := orderAt("stored", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	changed := orderAt("changed", time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+	insert(t, store, stored, OrderInserted)
+	insert(t, store, changed, OrderInserted)
+
+	changedAgain := changed
+	changedAgain.Items = append([]models.Item(nil), changed.Items...)
+	changedAgain.Items[0].Name = "Renamed"
+	fresh := orderAt("fresh", time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+	freshAgain := fresh
+	freshAgain.Delivery.City = "Haifa"
+
+	batch := []*models.Order{&stored, &changedAgain, &fresh, &fresh, &freshAgain}
+	outcomes, err := store.InsertBatch(batch)
+	if err != nil {
+		t.Fatalf("Failed to insert batch: %v", err)
+	}
+	want := []InsertOutcome{OrderUnchanged, OrderUpdated, OrderInserted, OrderUnchanged, OrderUpdated}
+	if fmt.Sprint(outcomes) != fmt.Sprint(want) {
+		t.Errorf("Batch outcomes are %v, wanted %v", outcomes, want)
+	}
+
+	for _, order := range []*models.Order{&stored, &changedAgain, &freshAgain} {
+		found, exist, err := store.FindOrderById(order.OrderUID)
+		if err != nil || !exist {
+			t.Fatalf("Order %s is not found after batch: exist %v, %v", order.OrderUID, exist, err)
+		}
+		assertSameOrder(t, &found, order)
+		if len(found.Items) != len(order.Items) {
+			t.Errorf("Order %s has %d items, wanted %d", order.OrderUID, len(found.Items), len(order.Items))
+		}
+	}
+}
+
+func testListFilters(t *testing.T, store OrderStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		orderAt("order-a", base),
+		orderAt("order-b", base.Add(24*time.Hour)),
+		orderAt("order-c", base.Add(48*time.Hour)),
+	}
+	orders[0].CustomerID = "alice"
+	orders[1].DeliveryService = "dhl"
+	orders[1].Locale = "ru"
+	orders[2].SmID = 7
+	orders[2].Payment.Currency = "EUR"
+	for _, order := range orders {
+		insert(t, store, order, OrderInserted)
+	}
+
+	smID := 7
+	tests := map[string]struct {
+		filter OrderFilter
+		want   []string
+	}{
+		"none":             {OrderFilter{}, []string{"order-c", "order-b", "order-a"}},
+		"customer_id":      {OrderFilter{CustomerID: "alice"}, []string{"order-a"}},
+		"delivery_service": {OrderFilter{DeliveryService: "dhl"}, []string{"order-b"}},
+		"locale":           {OrderFilter{Locale: "ru"}, []string{"order-b"}},
+		"sm_id":            {OrderFilter{SmID: &smID}, []string{"order-c"}},
+		"currency":         {OrderFilter{Currency: "EUR"}, []string{"order-c"}},
+		"from inclusive":   {OrderFilter{CreatedFrom: base.Add(24 * time.Hour)}, []string{"order-c", "order-b"}},
+		"to exclusive":     {OrderFilter{CreatedTo: base.Add(24 * time.Hour)}, []string{"order-a"}},
+		"no match":         {OrderFilter{CustomerID: "alice", Locale: "ru"}, nil},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			listed, err := store.ListOrders(ListQuery{Filter: test.filter})
+			if err != nil {
+				t.Fatalf("Failed to list orders: %v", err)
+			}
+			var uids []string
+			for _, order := range listed {
+				uids = append(uids, order.OrderUID)
+			}
+			if fmt.Sprint(uids) != fmt.Sprint(test.want) {
+				t.Errorf("Listed %v, wanted %v", uids, test.want)
+			}
+		})
+	}
+}
+
+func testFindOrdersByTrack(t *testing.T, store OrderStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	own := orderAt("own-track", base)
+	own.TrackNumber = "TRACK-1"
+	own.Items[0].TrackNumber = "TRACK-1"
+	byItem := orderAt("item-track", base.Add(time.Hour))
+	byItem.Items = append(byItem.Items, byItem.Items[0])
+	byItem.Items[1].TrackNumber = "TRACK-1"
+	unrelated := orderAt("unrelated", base.Add(2*time.Hour))
+	for _, order := range []models.Order{own, byItem, unrelated} {
+		insert(t, store, order, OrderInserted)
+	}
+
+	orders, err := store.FindOrdersByTrack("TRACK-1")
+	if err != nil {
+		t.Fatalf("Failed to find orders by track: %v", err)
+	}
+	var uids []string
+	for _, order := range orders {
+		uids = append(uids, order.OrderUID)
+	}
+	if fmt.Sprint(uids) != "[item-track own-track]" {
+		t.Errorf("Found %v, wanted [item-track own-track]", uids)
+	}
+
+	// The track number moves away from the order, the lookup must notice.
+	moved := own
+	moved.TrackNumber = "TRACK-2"
+	moved.Items = append([]models.Item(nil), own.Items...)
+	moved.Items[0].TrackNumber = "TRACK-2"
+	insert(t, store, moved, OrderUpdated)
+
+	orders, err = store.FindOrdersByTrack("TRACK-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].OrderUID != "item-track" {
+		t.Errorf("Found %d orders after the track number moved, wanted only item-track", len(orders))
+	}
+
+	if orders, err := store.FindOrdersByTrack("MISSING"); err != nil || len(orders) != 0 {
+		t.Errorf("Unknown track number found %d orders, %v", len(orders), err)
+	}
+}
+
+func testCustomerSummary(t *testing.T, store OrderStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	amounts := []struct {
+		currency string
+		amount   models.Money
+	}{{"USD", 181750}, {"USD", 1}, {"EUR", 99999}}
+	for i, payment := range amounts {
+		order := orderAt(fmt.Sprintf("order-%d", i), base.Add(time.Duration(i)*24*time.Hour))
+		order.CustomerID = "alice"
+		order.Payment.Currency = payment.currency
+		order.Payment.Amount = payment.amount
+		insert(t, store, order, OrderInserted)
+	}
+	other := orderAt("other", base.Add(-time.Hour))
+	other.CustomerID = "bob"
+	insert(t, store, other, OrderInserted)
+
+	summary, err := store.CustomerSummary("alice")
+	if err != nil {
+		t.Fatalf("Failed to summarize: %v", err)
+	}
+	if summary.OrderCount != 3 {
+		t.Errorf("Order count is %d, wanted 3", summary.OrderCount)
+	}
+	if fmt.Sprint(summary.Totals) != "map[EUR:999.99 USD:1817.51]" {
+		t.Errorf("Totals are %v", summary.Totals)
+	}
+	if summary.FirstOrderAt == nil || !summary.FirstOrderAt.Equal(base) {
+		t.Errorf("First order at %v, wanted %v", summary.FirstOrderAt, base)
+	}
+	if summary.LastOrderAt == nil || !summary.LastOrderAt.Equal(base.Add(48*time.Hour)) {
+		t.Errorf("Last order at %v, wanted %v", summary.LastOrderAt, base.Add(48*time.Hour))
+	}
+
+	empty, err := store.CustomerSummary("nobody")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.OrderCount != 0 || len(empty.Totals) != 0 || empty.FirstOrderAt != nil {
+		t.Errorf("Customer without orders has summary %+v", empty)
+	}
+}
+
+func testSearchOrders(t *testing.T, store OrderStore) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := orderAt("order-1", base)
+	first.Items[0].Name = "Mascara"
+	first.Items[0].Brand = "Vivienne Sabo"
+	second := orderAt("order-2", base.Add(time.Hour))
+	second.Delivery.Name = "Ivan Petrov"
+	second.Delivery.City = "Kazan"
+	second.Delivery.Phone = "+79123456789"
+	second.Delivery.Email = "petrov@example.com"
+	second.Items[0].Name = "Lipstick"
+	second.Items[0].Brand = "Maybelline"
+	for _, order := range []models.Order{first, second} {
+		insert(t, store, order, OrderInserted)
+	}
+
+	tests := map[string]struct {
+		text    string
+		want    []string
+		matched string
+	}{
+		"recipient name": {"testov", []string{"order-1"}, MatchDelivery},
+		"city":           {"Kazan", []string{"order-2"}, MatchDelivery},
+		"item brand":     {"vivienne sabo", []string{"order-1"}, MatchItem},
+		"item name":      {"Lipstick", []string{"order-2"}, MatchItem},
+		"phone":          {"3456789", []string{"order-2"}, MatchPhone},
+		"email":          {"petrov@example", []string{"order-2"}, MatchEmail},
+		"nothing":        {"nonexistent", nil, ""},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			summaries, err := store.SearchOrders(SearchQuery{Text: test.text, Limit: 10})
+			if err != nil {
+				t.Fatalf("Failed to search: %v", err)
+			}
+			var uids []string
+			for _, summary := range summaries {
+				uids = append(uids, summary.OrderUID)
+			}
+			if fmt.Sprint(uids) != fmt.Sprint(test.want) {
+				t.Fatalf("Found %v, wanted %v", uids, test.want)
+			}
+			if len(summaries) > 0 && !slices.Contains(summaries[0].MatchedIn, test.matched) {
+				t.Errorf("Matched in %v, wanted %s", summaries[0].MatchedIn, test.matched)
+			}
+		})
+	}
+
+	summaries, err := store.SearchOrders(SearchQuery{Text: "testov", Limit: 10})
+	if err != nil || len(summaries) != 1 {
+		t.Fatalf("Found %d orders, %v", len(summaries), err)
+	}
+	summary := summaries[0]
+	if summary.RecipientName != "Test Testov" || summary.Amount != first.Payment.Amount || summary.Currency != "USD" {
+		t.Errorf("Summary is %+v", summary)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxOrdersPerTrack bounds FindOrdersByTrack; a track number normally
+// belongs to one order.
+const maxOrdersPerTrack = 100
+
+// orderTracks returns the distinct track numbers of the order and its items.
+func orderTracks(order *models.Order) []string {
+	tracks := []string{order.TrackNumber}
+	for i := range order.Items {
+		if track := order.Items[i].TrackNumber; !slices.Contains(tracks, track) {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// HasTrack reports whether the order or one of its items has trackNumber.
+func HasTrack(order *models.Order, trackNumber string) bool {
+	if order.TrackNumber == trackNumber {
+		return true
+	}
+	for i := range order.Items {
+		if order.Items[i].TrackNumber == trackNumber {
+			return true
+		}
+	}
+	return false
+}
+
+// FindOrdersByTrack returns the orders that have trackNumber themselves or on
+// one of their items, newest first.
+//
+// The uids found for a track number are cached, the orders are taken from
+// the order cache. A cached entry is checked against the orders it points
+// to, so an order that lost the track number or was deleted sends the
+// lookup to the database; storing an order forgets its track numbers.
+func (repository *Repository) FindOrdersByTrack(trackNumber string) ([]models.Order, error) {
+	if repository.tracks != nil {
+		if uids, found := repository.tracks.Get(trackNumber); found {
+			if orders, ok := repository.cachedTrackOrders(trackNumber, uids); ok {
+				return orders, nil
+			}
+			repository.tracks.Remove(trackNumber)
+		}
+	}
+
+	ctx := context.Background()
+	rows, err := repository.pool.Query(ctx, selectOrderUIDsByTrack, trackNumber, maxOrdersPerTrack)
+	if err != nil {
+		return nil, fmt.Errorf("query order uids by track: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("collect order uids by track: %w", err)
+	}
+
+	gens := make([]uint64, len(uids))
+	for i, uid := range uids {
+		gens[i] = repository.generations.current(uid)
+	}
+	loaded, err := repository.loadOrders(ctx, uids)
+	if err != nil {
+		return nil, err
+	}
+	orders := make([]models.Order, 0, len(uids))
+	for i, uid := range uids {
+		order, found := loaded[uid]
+		if !found {
+			continue
+		}
+		repository.generations.ifCurrent(uid, gens[i], func() {
+			repository.cacheOrder(*order)
+		})
+		orders = append(orders, *order)
+	}
+
+	if repository.tracks != nil {
+		repository.tracks.Add(trackNumber, uids)
+	}
+	return orders, nil
+}
+
+// cachedTrackOrders resolves cached uids through FindOrderById and reports
+// false if any of them no longer has trackNumber.
+func (repository *Repository) cachedTrackOrders(trackNumber string, uids []string) ([]models.Order, bool) {
+	orders := make([]models.Order, 0, len(uids))
+	for _, uid := range uids {
+		order, exist, err := repository.FindOrderById(uid)
+		if err != nil || !exist || !HasTrack(&order, trackNumber) {
+			return nil, false
+		}
+		orders = append(orders, order)
+	}
+	return orders, true
+}
+
+func (repository *Repository) forgetTracks(tracks []string) {
+	if repository.tracks == nil {
+		return
+	}
+	for _, track := range tracks {
+		repository.tracks.Remove(track)
+	}
+}
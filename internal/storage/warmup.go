@@ -0,0 +1,305 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"test-task/internal/cache"
+	"test-task/pkg/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// WarmupOrder selects which orders are loaded into the cache at startup.
+type WarmupOrder string
+
+const (
+	// WarmupRecent loads the most recent orders by date_created.
+	WarmupRecent WarmupOrder = "recent"
+	// WarmupNone loads any orders, in the order the database returns them.
+	WarmupNone WarmupOrder = "none"
+)
+
+type CacheOptions struct {
+	Capacity int
+	// MaxBytes and TTL are disabled when zero, see cache.Options.
+	MaxBytes int64
+	TTL      time.Duration
+	Policy   cache.PolicyName
+	// WarmupSize is the number of orders loaded at startup, 0 disables warm-up.
+	WarmupSize  int
+	WarmupOrder WarmupOrder
+	// NegativeTTL is how long unknown uids are remembered, 0 disables it.
+	NegativeTTL      time.Duration
+	NegativeCapacity int
+	// TrackTTL is how long track number lookups are cached, 0 disables it.
+	TrackTTL      time.Duration
+	TrackCapacity int
+	// SnapshotPath is where the cache is saved on Close and loaded from at
+	// startup instead of warming up from the database. Empty disables it.
+	SnapshotPath   string
+	SnapshotMaxAge time.Duration
+}
+
+// WarmUpCache loads up to WarmupSize orders into the cache using one query
+// per table instead of one lookup per order.
+func (repository *Repository) WarmUpCache() error {
+	size := min(repository.cacheOptions.WarmupSize, repository.cacheOptions.Capacity)
+	if size <= 0 {
+		return nil
+	}
+	ctx := context.Background()
+
+	query := selectAnyOrderUIDs
+	if repository.cacheOptions.WarmupOrder == WarmupRecent {
+		query = selectRecentOrderUIDs
+	}
+
+	rows, err := repository.pool.Query(ctx, query, size)
+	if err != nil {
+		return fmt.Errorf("query order uids: %w", err)
+	}
+	uids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("collect order uids: %w", err)
+	}
+
+	orders, err := repository.loadOrders(ctx, uids)
+	if err != nil {
+		return err
+	}
+
+	// Add the least important orders first, so the first ones end up at the
+	// front of the LRU list.
+	for i := len(uids) - 1; i >= 0; i-- {
+		if order, found := orders[uids[i]]; found {
+			repository.cacheOrder(*order)
+		}
+	}
+
+	log.Printf("Cache is warmed up with %d orders", len(orders))
+	return nil
+}
+
+// loadCacheSnapshot fills the cache from the snapshot file and reports
+// whether it did. A missing, corrupt or stale snapshot is not an error, the
+// caller falls back to WarmUpCache. Orders changed in the database since the
+// snapshot was saved are dropped from the cache.
+func (repository *Repository) loadCacheSnapshot() bool {
+	path := repository.cacheOptions.SnapshotPath
+	if path == "" {
+		return false
+	}
+
+	loaded, err := repository.cache.LoadSnapshotFile(path, repository.cacheOptions.SnapshotMaxAge)
+	if err != nil {
+		log.Printf("Unable to load cache snapshot %s, warming up from the DB: %v", path, err)
+		repository.cache.Flush()
+		return false
+	}
+	dropped, err := repository.dropChangedOrders(context.Background())
+	if err != nil {
+		log.Printf("Unable to check cache snapshot %s, warming up from the DB: %v", path, err)
+		repository.cache.Flush()
+		return false
+	}
+	log.Printf("Cache is loaded from snapshot %s with %d orders, %d changed since dropped",
+		path, loaded-dropped, dropped)
+	return true
+}
+
+// dropChangedOrders compares the content hash of every cached order with the
+// stored one in a single query, and drops the orders that were changed or
+// deleted. It returns how many orders were dropped.
+func (repository *Repository) dropChangedOrders(ctx context.Context) (int, error) {
+	orders := repository.cache.Orders()
+	if len(orders) == 0 {
+		return 0, nil
+	}
+	uids := make([]string, len(orders))
+	hashes := make(map[string]string, len(orders))
+	for i, order := range orders {
+		hash, err := contentHash(order)
+		if err != nil {
+			return 0, err
+		}
+		uids[i] = order.OrderUID
+		hashes[order.OrderUID] = hash
+	}
+
+	rows, err := repository.pool.Query(ctx, selectStoredOrderHashes, uids)
+	if err != nil {
+		return 0, fmt.Errorf("query order hashes: %w", err)
+	}
+	unchanged := make(map[string]bool, len(orders))
+	for rows.Next() {
+		var orderUid, hash string
+		if err := rows.Scan(&orderUid, &hash); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan order hash: %w", err)
+		}
+		unchanged[orderUid] = hash == hashes[orderUid]
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("query order hashes: %w", err)
+	}
+
+	dropped := 0
+	for _, orderUid := range uids {
+		if !unchanged[orderUid] && repository.cache.Remove(orderUid) {
+			dropped++
+		}
+	}
+	return dropped, nil
+}
+
+func (repository *Repository) saveCacheSnapshot() {
+	path := repository.cacheOptions.SnapshotPath
+	if path == "" || repository.cache == nil {
+		return
+	}
+
+	if err := repository.cache.SaveSnapshotFile(path); err != nil {
+		log.Printf("Unable to save cache snapshot %s: %v", path, err)
+		return
+	}
+	log.Printf("Cache snapshot is saved to %s", path)
+}
+
+// RewarmCache drops every cached order and warms the cache up again.
+func (repository *Repository) RewarmCache() error {
+	repository.FlushCache()
+	return repository.WarmUpCache()
+}
+
+func (repository *Repository) FlushCache() {
+	repository.cache.Flush()
+	if repository.negative != nil {
+		repository.negative.Flush()
+	}
+	if repository.tracks != nil {
+		repository.tracks.Flush()
+	}
+	log.Printf("Cache is flushed")
+}
+
+func (repository *Repository) CacheStats() cache.Stats {
+	return repository.cache.Stats()
+}
+
+func (repository *Repository) IsCached(orderUid string) bool {
+	return repository.cache.Contains(orderUid)
+}
+
+// EvictFromCache drops one order from the cache and reports whether it was cached.
+func (repository *Repository) EvictFromCache(orderUid string) bool {
+	if repository.negative != nil {
+		repository.negative.Remove(orderUid)
+	}
+	return repository.cache.Remove(orderUid)
+}
+
+// loadOrders reads complete orders with the given uids in a single
+// transaction. Unknown uids are absent from the result.
+func (repository *Repository) loadOrders(ctx context.Context, uids []string) (map[string]*models.Order, error) {
+	orders := make(map[string]*models.Order, len(uids))
+	if len(uids) == 0 {
+		return orders, nil
+	}
+
+	tx, err := repository.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, selectOrdersByUIDs, uids)
+	if err != nil {
+		return nil, fmt.Errorf("query orders: %w", err)
+	}
+	for rows.Next() {
+		order := &models.Order{}
+		err = rows.Scan(
+			&order.OrderUID, &order.TrackNumber, &order.Entry,
+			&order.Locale, &order.InternalSignature, &order.CustomerID,
+			&order.DeliveryService, &order.Shardkey, &order.SmID,
+			&order.DateCreated, &order.OofShard,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan order: %w", err)
+		}
+		orders[order.OrderUID] = order
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query orders: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, selectDeliveriesByUIDs, uids)
+	if err != nil {
+		return nil, fmt.Errorf("query deliveries: %w", err)
+	}
+	for rows.Next() {
+		var delivery models.Delivery
+		err = rows.Scan(
+			&delivery.OrderUID, &delivery.Name, &delivery.Phone,
+			&delivery.Zip, &delivery.City, &delivery.Address,
+			&delivery.Region, &delivery.Email,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		if order, found := orders[delivery.OrderUID]; found {
+			order.Delivery = delivery
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query deliveries: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, selectPaymentsByUIDs, uids)
+	if err != nil {
+		return nil, fmt.Errorf("query payments: %w", err)
+	}
+	for rows.Next() {
+		var payment models.Payment
+		err = rows.Scan(
+			&payment.OrderUID, &payment.Transaction, &payment.RequestID,
+			&payment.Currency, &payment.Provider, &payment.Amount,
+			&payment.PaymentDt, &payment.Bank, &payment.DeliveryCost,
+			&payment.GoodsTotal, &payment.CustomFee,
+		)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan payment: %w", err)
+		}
+		if order, found := orders[payment.OrderUID]; found {
+			order.Payment = payment
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query payments: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, selectItemsByUIDs, uids)
+	if err != nil {
+		return nil, fmt.Errorf("query items: %w", err)
+	}
+	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Item])
+	if err != nil {
+		return nil, fmt.Errorf("collect items: %w", err)
+	}
+	for _, item := range items {
+		if order, found := orders[item.OrderUID]; found {
+			order.Items = append(order.Items, item)
+		}
+	}
+
+	return orders, nil
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"test-task/pkg/models"
+)
+
+// TestRepositoryWarmUp needs TEST_DATABASE_URL, like TestRepository.
+func TestRepositoryWarmUp(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	migrateTestDatabase(t, connStr)
+
+	const stored = 5
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	uid := func(i int) string { return fmt.Sprintf("warm-%d", i) }
+
+	writer := openTestRepository(t, connStr, CacheOptions{Capacity: 10})
+	if _, err := writer.pool.Exec(context.Background(), `TRUNCATE "orders" CASCADE`); err != nil {
+		t.Fatalf("Failed to truncate orders: %v", err)
+	}
+	// Inserted oldest last, so the insertion order does not match the dates.
+	for i := stored - 1; i >= 0; i-- {
+		insert(t, writer, orderAt(uid(i), base.Add(time.Duration(i)*time.Hour)), OrderInserted)
+	}
+
+	t.Run("RecentFillsCapacityNewestFirst", func(t *testing.T) {
+		repository := openTestRepository(t, connStr, CacheOptions{
+			Capacity: 3, WarmupSize: 10, WarmupOrder: WarmupRecent,
+		})
+
+		if got := repository.cache.Len(); got != 3 {
+			t.Fatalf("Warmed up %d orders, wanted min(WarmupSize, Capacity) = 3", got)
+		}
+		for i := stored - 3; i < stored; i++ {
+			if !repository.IsCached(uid(i)) {
+				t.Errorf("Recent order %s is not warmed up", uid(i))
+			}
+		}
+
+		// Two more orders evict the two least recently used ones, which
+		// must be the oldest of the warmed up orders.
+		for i := range 2 {
+			extra := orderAt(fmt.Sprintf("extra-%d", i), base)
+			repository.cacheOrder(extra)
+		}
+		for i := stored - 3; i < stored-1; i++ {
+			if repository.IsCached(uid(i)) {
+				t.Errorf("Older order %s should be behind newer ones in the LRU list", uid(i))
+			}
+		}
+		if !repository.IsCached(uid(stored - 1)) {
+			t.Errorf("Newest order %s should be at the front of the LRU list", uid(stored-1))
+		}
+	})
+
+	t.Run("SizeBelowCapacity", func(t *testing.T) {
+		repository := openTestRepository(t, connStr, CacheOptions{
+			Capacity: 10, WarmupSize: 2, WarmupOrder: WarmupNone,
+		})
+
+		if got := repository.cache.Len(); got != 2 {
+			t.Errorf("Warmed up %d orders, wanted WarmupSize = 2", got)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		repository := openTestRepository(t, connStr, CacheOptions{Capacity: 10})
+
+		if got := repository.cache.Len(); got != 0 {
+			t.Errorf("Warmed up %d orders with warm-up disabled", got)
+		}
+	})
+}
+
+func openTestRepository(t *testing.T, connStr string, options CacheOptions) *Repository {
+	t.Helper()
+	repository := &Repository{}
+	if err := repository.InitRepository(connStr, options); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	t.Cleanup(repository.Close)
+	return repository
+}
+
+// TestRepositorySnapshotDropsChangedOrders needs TEST_DATABASE_URL, like
+// TestRepository.
+func TestRepositorySnapshotDropsChangedOrders(t *testing.T) {
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if connStr == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	migrateTestDatabase(t, connStr)
+
+	options := CacheOptions{Capacity: 10, SnapshotPath: filepath.Join(t.TempDir(), "cache.snapshot")}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	kept, changed, deleted := orderAt("snap-kept", base), orderAt("snap-changed", base), orderAt("snap-deleted", base)
+
+	// The snapshot is saved on Close with every order inserted through it.
+	writer := &Repository{}
+	if err := writer.InitRepository(connStr, options); err != nil {
+		t.Fatalf("Failed to init repository: %v", err)
+	}
+	if _, err := writer.pool.Exec(context.Background(), `TRUNCATE "orders" CASCADE`); err != nil {
+		t.Fatalf("Failed to truncate orders: %v", err)
+	}
+	for _, order := range []models.Order{kept, changed, deleted} {
+		insert(t, writer, order, OrderInserted)
+	}
+	writer.Close()
+
+	// Changes made while no instance runs reach no cache.
+	offline := openTestRepository(t, connStr, CacheOptions{Capacity: 10})
+	changed.TrackNumber = "CHANGEDTRACK"
+	insert(t, offline, changed, OrderUpdated)
+	if _, err := offline.DeleteOrder(deleted.OrderUID); err != nil {
+		t.Fatalf("Failed to delete %s: %v", deleted.OrderUID, err)
+	}
+
+	repository := openTestRepository(t, connStr, options)
+	if !repository.IsCached(kept.OrderUID) {
+		t.Errorf("Unchanged order %s should be loaded from the snapshot", kept.OrderUID)
+	}
+	for _, orderUid := range []string{changed.OrderUID, deleted.OrderUID} {
+		if repository.IsCached(orderUid) {
+			t.Errorf("Order %s changed since the snapshot should be dropped", orderUid)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"test-task/pkg/models"
+)
+
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+// FieldError describes a single invalid field. Field is the JSON path of the
+// field, e.g. "payment.amount" or "items[1].size".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors is returned by ValidateOrder when at least one field is invalid.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldErr := range e {
+		messages[i] = fieldErr.Error()
+	}
+	return "invalid order: " + strings.Join(messages, "; ")
+}
+
+type validator struct {
+	errors Errors
+}
+
+func (v *validator) add(field, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "is required")
+	}
+}
+
+// maxLen mirrors the VARCHAR limits of the database schema.
+func (v *validator) maxLen(field, value string, limit int) {
+	if length := utf8.RuneCountInString(value); length > limit {
+		v.add(field, "is %d characters long, at most %d allowed", length, limit)
+	}
+}
+
+func (v *validator) nonNegative(field string, value models.Money) {
+	if value < 0 {
+		v.add(field, "must not be negative")
+	}
+}
+
+// fitsCurrency checks that the amount has no fractions of the currency's
+// smallest unit.
+func (v *validator) fitsCurrency(field string, value models.Money, currency string) {
+	if !value.FitsCurrency(currency) {
+		v.add(field, "has more decimal places than %s allows", currency)
+	}
+}
+
+// ValidateOrder checks an incoming order and returns Errors listing every
+// invalid field, or nil if the order can be stored.
+func ValidateOrder(order *models.Order) error {
+	v := &validator{}
+
+	v.required("order_uid", order.OrderUID)
+	v.maxLen("order_uid", order.OrderUID, 255)
+	v.required("track_number", order.TrackNumber)
+	v.maxLen("track_number", order.TrackNumber, 255)
+	v.required("entry", order.Entry)
+	v.maxLen("entry", order.Entry, 50)
+	v.maxLen("locale", order.Locale, 255)
+	v.maxLen("internal_signature", order.InternalSignature, 255)
+	v.required("customer_id", order.CustomerID)
+	v.maxLen("customer_id", order.CustomerID, 255)
+	v.maxLen("delivery_service", order.DeliveryService, 100)
+	v.maxLen("shardkey", order.Shardkey, 10)
+	v.maxLen("oof_shard", order.OofShard, 10)
+	if order.DateCreated.IsZero() {
+		v.add("date_created", "is required")
+	}
+
+	validateDelivery(v, &order.Delivery)
+	validatePayment(v, &order.Payment)
+
+	if len(order.Items) == 0 {
+		v.add("items", "must contain at least one item")
+	}
+	var itemsTotal models.Money
+	for i := range order.Items {
+		path := fmt.Sprintf("items[%d]", i)
+		validateItem(v, path, &order.Items[i])
+		v.fitsCurrency(path+".price", order.Items[i].Price, order.Payment.Currency)
+		v.fitsCurrency(path+".total_price", order.Items[i].TotalPrice, order.Payment.Currency)
+		itemsTotal += order.Items[i].TotalPrice
+	}
+
+	payment := &order.Payment
+	// Money is kept in minor units, so totals have to match exactly.
+	if payment.GoodsTotal != itemsTotal {
+		v.add("payment.goods_total", "is %v, but items total_price sum is %v",
+			payment.GoodsTotal, itemsTotal)
+	}
+	expectedAmount := payment.DeliveryCost + payment.GoodsTotal + payment.CustomFee
+	if payment.Amount != expectedAmount {
+		v.add("payment.amount", "is %v, but delivery_cost + goods_total + custom_fee is %v",
+			payment.Amount, expectedAmount)
+	}
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+	return nil
+}
+
+func validateDelivery(v *validator, delivery *models.Delivery) {
+	v.required("delivery.name", delivery.Name)
+	v.maxLen("delivery.name", delivery.Name, 255)
+	v.maxLen("delivery.zip", delivery.Zip, 20)
+	v.maxLen("delivery.city", delivery.City, 100)
+	v.maxLen("delivery.region", delivery.Region, 100)
+
+	v.required("delivery.phone", delivery.Phone)
+	v.maxLen("delivery.phone", delivery.Phone, 20)
+	if delivery.Phone != "" && !phonePattern.MatchString(normalizePhone(delivery.Phone)) {
+		v.add("delivery.phone", "is not a valid phone number")
+	}
+
+	v.required("delivery.email", delivery.Email)
+	v.maxLen("delivery.email", delivery.Email, 100)
+	if delivery.Email != "" {
+		address, err := mail.ParseAddress(delivery.Email)
+		if err != nil || address.Address != delivery.Email {
+			v.add("delivery.email", "is not a valid email address")
+		}
+	}
+}
+
+func validatePayment(v *validator, payment *models.Payment) {
+	v.required("payment.transaction", payment.Transaction)
+	v.maxLen("payment.transaction", payment.Transaction, 255)
+	v.maxLen("payment.request_id", payment.RequestID, 255)
+	v.required("payment.currency", payment.Currency)
+	v.maxLen("payment.currency", payment.Currency, 10)
+	v.maxLen("payment.provider", payment.Provider, 50)
+	v.maxLen("payment.bank", payment.Bank, 50)
+
+	if exponent := models.CurrencyExponent(payment.Currency); exponent > 2 {
+		v.add("payment.currency", "has %d decimal places, at most 2 supported", exponent)
+	}
+
+	v.nonNegative("payment.amount", payment.Amount)
+	v.nonNegative("payment.delivery_cost", payment.DeliveryCost)
+	v.nonNegative("payment.goods_total", payment.GoodsTotal)
+	v.nonNegative("payment.custom_fee", payment.CustomFee)
+
+	v.fitsCurrency("payment.amount", payment.Amount, payment.Currency)
+	v.fitsCurrency("payment.delivery_cost", payment.DeliveryCost, payment.Currency)
+	v.fitsCurrency("payment.goods_total", payment.GoodsTotal, payment.Currency)
+	v.fitsCurrency("payment.custom_fee", payment.CustomFee, payment.Currency)
+}
+
+func validateItem(v *validator, path string, item *models.Item) {
+	v.maxLen(path+".track_number", item.TrackNumber, 255)
+	v.maxLen(path+".rid", item.Rid, 255)
+	v.required(path+".name", item.Name)
+	v.maxLen(path+".name", item.Name, 255)
+	v.maxLen(path+".size", item.Size, 10)
+	v.maxLen(path+".brand", item.Brand, 255)
+
+	v.nonNegative(path+".price", item.Price)
+	if item.Sale < 0 || item.Sale > 100 {
+		v.add(path+".sale", "must be between 0 and 100")
+	}
+	v.nonNegative(path+".total_price", item.TotalPrice)
+}
+
+// normalizePhone drops the separators people commonly put into phone numbers.
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+}
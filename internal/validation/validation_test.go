@@ -0,0 +1,178 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task/pkg/models"
+)
+
+func validOrder() models.Order {
+	return models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+			CustomFee:    0,
+		},
+		Items: []models.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func fieldErrors(t *testing.T, order *models.Order) map[string]string {
+	t.Helper()
+	err := ValidateOrder(order)
+	if err == nil {
+		return nil
+	}
+	var validationErrors Errors
+	if !errors.As(err, &validationErrors) {
+		t.Fatalf("Expected validation.Errors, got %T", err)
+	}
+	fields := make(map[string]string)
+	for _, fieldErr := range validationErrors {
+		fields[fieldErr.Field] = fieldErr.Message
+	}
+	return fields
+}
+
+func TestValidateOrder_Valid(t *testing.T) {
+	order := validOrder()
+	if err := ValidateOrder(&order); err != nil {
+		t.Errorf("Valid order is rejected: %v", err)
+	}
+}
+
+func TestValidateOrder_Fields(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(order *models.Order)
+		field  string
+	}{
+		{"empty order_uid", func(o *models.Order) { o.OrderUID = " " }, "order_uid"},
+		{"long shardkey", func(o *models.Order) { o.Shardkey = "12345678901" }, "shardkey"},
+		{"long entry", func(o *models.Order) { o.Entry = strings.Repeat("e", 51) }, "entry"},
+		{"no date", func(o *models.Order) { o.DateCreated = time.Time{} }, "date_created"},
+		{"bad email", func(o *models.Order) { o.Delivery.Email = "test at gmail" }, "delivery.email"},
+		{"email with name", func(o *models.Order) { o.Delivery.Email = "Test <test@gmail.com>" }, "delivery.email"},
+		{"bad phone", func(o *models.Order) { o.Delivery.Phone = "call me" }, "delivery.phone"},
+		{"short phone", func(o *models.Order) { o.Delivery.Phone = "+123" }, "delivery.phone"},
+		{"long currency", func(o *models.Order) { o.Payment.Currency = "DOLLARS_USA" }, "payment.currency"},
+		{"no items", func(o *models.Order) { o.Items = nil }, "items"},
+		{"long item size", func(o *models.Order) { o.Items[0].Size = "extra large" }, "items[0].size"},
+		{"item sale", func(o *models.Order) { o.Items[0].Sale = 101 }, "items[0].sale"},
+		{"goods total", func(o *models.Order) {
+			o.Payment.GoodsTotal = 300
+			o.Payment.Amount = 1800
+		}, "payment.goods_total"},
+		{"amount", func(o *models.Order) { o.Payment.Amount = 1818 }, "payment.amount"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := validOrder()
+			tt.modify(&order)
+			fields := fieldErrors(t, &order)
+			if _, found := fields[tt.field]; !found {
+				t.Errorf("Expected error for %s, got %v", tt.field, fields)
+			}
+		})
+	}
+}
+
+func TestValidateOrder_ReportsEveryField(t *testing.T) {
+	order := validOrder()
+	order.OrderUID = ""
+	order.Delivery.Email = "wrong"
+	order.Payment.Amount = 1
+
+	fields := fieldErrors(t, &order)
+	for _, field := range []string{"order_uid", "delivery.email", "payment.amount"} {
+		if _, found := fields[field]; !found {
+			t.Errorf("Expected error for %s, got %v", field, fields)
+		}
+	}
+}
+
+func TestValidateOrder_PhoneSeparators(t *testing.T) {
+	order := validOrder()
+	order.Delivery.Phone = "+7 (912) 345-67-89"
+	if err := ValidateOrder(&order); err != nil {
+		t.Errorf("Phone with separators is rejected: %v", err)
+	}
+}
+
+func TestValidateOrder_CurrencyDecimals(t *testing.T) {
+	// wholeUnits scales every amount of the order to whole currency units.
+	wholeUnits := func(o *models.Order) {
+		o.Payment.Amount *= models.MinorUnits
+		o.Payment.DeliveryCost *= models.MinorUnits
+		o.Payment.GoodsTotal *= models.MinorUnits
+		o.Items[0].Price *= models.MinorUnits
+		o.Items[0].TotalPrice *= models.MinorUnits
+	}
+
+	t.Run("JPY", func(t *testing.T) {
+		order := validOrder()
+		order.Payment.Currency = "JPY"
+		wholeUnits(&order)
+		if err := ValidateOrder(&order); err != nil {
+			t.Errorf("Whole yen amounts are rejected: %v", err)
+		}
+
+		order.Items[0].Price += 50
+		fields := fieldErrors(t, &order)
+		if _, found := fields["items[0].price"]; !found {
+			t.Errorf("Expected error for a fraction of a yen, got %v", fields)
+		}
+	})
+	for _, currency := range []string{"KWD", "BHD"} {
+		t.Run(currency, func(t *testing.T) {
+			order := validOrder()
+			order.Payment.Currency = currency
+			wholeUnits(&order)
+			fields := fieldErrors(t, &order)
+			if _, found := fields["payment.currency"]; !found {
+				t.Errorf("Expected error for a currency with three decimals, got %v", fields)
+			}
+		})
+	}
+}
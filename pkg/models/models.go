@@ -16,7 +16,7 @@ type Order struct {
 	Items             []Item    `json:"items" fake:"skip"`
 	Locale            string    `json:"locale" fake:"{languageabbreviation}"`
 	InternalSignature string    `json:"internal_signature" fake:"skip"`
-	CustomerID        string    `json:"customer_id" fake:"{uuid}""`
+	CustomerID        string    `json:"customer_id" fake:"{uuid}"`
 	DeliveryService   string    `json:"delivery_service" fake:"{company}"`
 	Shardkey          string    `json:"shardkey"`
 	SmID              int       `json:"sm_id" fake:"{number:1,100}"`
@@ -36,33 +36,33 @@ type Delivery struct {
 }
 
 type Payment struct {
-	OrderUID     string  `json:"-" db:"order_uid"`
-	Transaction  string  `json:"transaction" fake:"{uuid}"`
-	RequestID    string  `json:"request_id" fake:"{uuid}"`
-	Currency     string  `json:"currency" fake:"{currencyshort}"`
-	Provider     string  `json:"provider" fake:"{company}"`
-	Amount       float64 `json:"amount"`
-	PaymentDt    int     `json:"payment_dt" fake:"{number:100,1000}"`
-	Bank         string  `json:"bank" fake:"{bankname}"`
-	DeliveryCost float64 `json:"delivery_cost" fake:"{price:1,1000}"`
-	GoodsTotal   float64 `json:"goods_total"`
-	CustomFee    float64 `json:"custom_fee" fake:"{price:1,1000}"`
+	OrderUID     string `json:"-" db:"order_uid"`
+	Transaction  string `json:"transaction" fake:"{uuid}"`
+	RequestID    string `json:"request_id" fake:"{uuid}"`
+	Currency     string `json:"currency" fake:"{randomstring:[USD,EUR,RUB,KZT,CNY]}"`
+	Provider     string `json:"provider" fake:"{company}"`
+	Amount       Money  `json:"amount"`
+	PaymentDt    int    `json:"payment_dt" fake:"{number:100,1000}"`
+	Bank         string `json:"bank" fake:"{bankname}"`
+	DeliveryCost Money  `json:"delivery_cost" fake:"{number:100,100000}"`
+	GoodsTotal   Money  `json:"goods_total"`
+	CustomFee    Money  `json:"custom_fee" fake:"{number:100,100000}"`
 }
 
 type Item struct {
-	ID          int     `json:"-"`
-	OrderUID    string  `json:"-"`
-	ChrtID      int64   `json:"chrt_id"fake:"{number:1,10000}"`
-	TrackNumber string  `json:"track_number" `
-	Price       int     `json:"price" fake:"{number:1000,10000}"`
-	Rid         string  `json:"rid" fake:"{uuid}"`
-	Name        string  `json:"name" fake:"{productname}"`
-	Sale        int     `json:"sale" fake:"{number:0,100}"`
-	Size        string  `json:"size" fake:"{number:0,100}"`
-	TotalPrice  float64 `json:"total_price"`
-	NmID        int64   `json:"nm_id" fake:"{number:10000,99999}"`
-	Brand       string  `json:"brand" fake:"{company}"`
-	Status      int     `json:"status" fake:"{number:200,202}"`
+	ID          int    `json:"-"`
+	OrderUID    string `json:"-"`
+	ChrtID      int64  `json:"chrt_id" fake:"{number:1,10000}"`
+	TrackNumber string `json:"track_number" `
+	Price       Money  `json:"price" fake:"{number:100000,1000000}"`
+	Rid         string `json:"rid" fake:"{uuid}"`
+	Name        string `json:"name" fake:"{productname}"`
+	Sale        int    `json:"sale" fake:"{number:0,100}"`
+	Size        string `json:"size" fake:"{number:0,100}"`
+	TotalPrice  Money  `json:"total_price"`
+	NmID        int64  `json:"nm_id" fake:"{number:10000,99999}"`
+	Brand       string `json:"brand" fake:"{company}"`
+	Status      int    `json:"status" fake:"{number:200,202}"`
 }
 
 func createRandomOrder(rng *rand.Rand) Order {
@@ -82,7 +82,7 @@ func createRandomOrder(rng *rand.Rand) Order {
 
 	itemCount := rng.Intn(10) + 1
 	items := make([]Item, 0, itemCount)
-	var goodsTotal float64
+	var goodsTotal Money
 
 	for i := 0; i < itemCount; i++ {
 		var item Item
@@ -94,7 +94,7 @@ func createRandomOrder(rng *rand.Rand) Order {
 
 		item.Sale = rng.Intn(51)
 
-		item.TotalPrice = float64(item.Price*quantity) * (1 - float64(item.Sale)/100.0)
+		item.TotalPrice = item.Price.Times(quantity).Percent(100 - item.Sale)
 
 		goodsTotal += item.TotalPrice
 		items = append(items, item)
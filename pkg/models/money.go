@@ -0,0 +1,160 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in hundredths of the payment currency, so 1817.50 is
+// stored as 181750, whatever the currency's own minor unit is. It is encoded in JSON as an exact decimal
+// number and in the database as BIGINT.
+type Money int64
+
+// MinorUnits is the number of minor units in one unit of currency.
+const MinorUnits = 100
+
+// currencyExponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth, by their number of decimal places.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// CurrencyExponent returns the number of decimal places of an ISO 4217
+// currency code, 2 for codes it does not know. Money represents amounts of
+// currencies with an exponent of at most 2.
+func CurrencyExponent(currency string) int {
+	if exponent, found := currencyExponents[strings.ToUpper(currency)]; found {
+		return exponent
+	}
+	return 2
+}
+
+// FitsCurrency reports whether the amount has no more decimal places than
+// the currency allows: 1817.5 fits USD but not JPY.
+func (m Money) FitsCurrency(currency string) bool {
+	exponent := CurrencyExponent(currency)
+	if exponent >= 2 {
+		return true
+	}
+	step := Money(1)
+	for range 2 - exponent {
+		step *= 10
+	}
+	return m%step == 0
+}
+
+var errInvalidMoney = errors.New("invalid money amount")
+
+// ParseMoney parses a decimal amount like "1817", "-3.5" or "0.99". More
+// than two fractional digits are accepted only when the extra ones are
+// zeros: an amount is never rounded silently.
+func ParseMoney(text string) (Money, error) {
+	digits := strings.TrimPrefix(text, "-")
+	negative := len(digits) < len(text)
+
+	whole, fraction, hasPoint := strings.Cut(digits, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || hasPoint && fraction == "" {
+		return 0, fmt.Errorf("%w %q", errInvalidMoney, text)
+	}
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > 2 {
+		return 0, fmt.Errorf("%w %q: more than two fractional digits", errInvalidMoney, text)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/MinorUnits-1 {
+		return 0, fmt.Errorf("%w %q: out of range", errInvalidMoney, text)
+	}
+	minor, _ := strconv.ParseInt((fraction + "00")[:2], 10, 64)
+
+	amount := Money(units*MinorUnits + minor)
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func isDigits(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] < '0' || text[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount as a decimal without trailing fractional
+// zeros: 181700 is "1817", 181750 is "1817.5".
+func (m Money) String() string {
+	sign := ""
+	abs := int64(m)
+	if abs < 0 {
+		sign = "-"
+		abs = -abs
+	}
+	units, minor := abs/MinorUnits, abs%MinorUnits
+	if minor == 0 {
+		return sign + strconv.FormatInt(units, 10)
+	}
+	fraction := strings.TrimRight(fmt.Sprintf("%02d", minor), "0")
+	return sign + strconv.FormatInt(units, 10) + "." + fraction
+}
+
+// Percent returns percent of the amount rounded half away from zero to the
+// nearest minor unit.
+func (m Money) Percent(percent int) Money {
+	product := int64(m) * int64(percent)
+	if product < 0 {
+		return Money((product - 50) / 100)
+	}
+	return Money((product + 50) / 100)
+}
+
+// Times multiplies the amount by a quantity.
+func (m Money) Times(quantity int) Money {
+	return m * Money(quantity)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON reads the number literal itself instead of going through
+// float64, so no precision is lost.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	amount, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return int64(m), nil
+}
+
+func (m *Money) Scan(src any) error {
+	switch value := src.(type) {
+	case int64:
+		*m = Money(value)
+	case int32:
+		*m = Money(value)
+	case nil:
+		*m = 0
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+	return nil
+}
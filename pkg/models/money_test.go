@@ -0,0 +1,169 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := map[string]Money{
+		"0":       0,
+		"1817":    181700,
+		"1817.5":  181750,
+		"1817.50": 181750,
+		"0.99":    99,
+		"0.01":    1,
+		"-3.5":    -350,
+		"12.300":  1230,
+	}
+	for text, want := range tests {
+		got, err := ParseMoney(text)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) failed: %v", text, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseMoney(%q) = %d, wanted %d", text, got, want)
+		}
+	}
+}
+
+func TestParseMoney_Invalid(t *testing.T) {
+	for _, text := range []string{"", "-", ".5", "1.", "1.999", "0.001", "1e3", "1,5", `"12"`, "99999999999999999999"} {
+		if got, err := ParseMoney(text); err == nil {
+			t.Errorf("ParseMoney(%q) = %d, wanted an error", text, got)
+		}
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	tests := map[Money]string{
+		0:      "0",
+		181700: "1817",
+		181750: "1817.5",
+		181705: "1817.05",
+		1:      "0.01",
+		-350:   "-3.5",
+		-5:     "-0.05",
+	}
+	for amount, want := range tests {
+		if got := amount.String(); got != want {
+			t.Errorf("Money(%d).String() = %q, wanted %q", int64(amount), got, want)
+		}
+	}
+}
+
+func TestMoney_Percent(t *testing.T) {
+	tests := []struct {
+		amount  Money
+		percent int
+		want    Money
+	}{
+		{45300, 70, 31710},
+		{1, 50, 1},   // 0.5 minor units round up
+		{1, 49, 0},   // 0.49 minor units round down
+		{-1, 50, -1}, // half rounds away from zero
+		{999, 100, 999},
+		{999, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Percent(tt.percent); got != tt.want {
+			t.Errorf("Money(%d).Percent(%d) = %d, wanted %d", int64(tt.amount), tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	for _, amount := range []Money{0, 1, 10, 99, 100, 181750, 1<<53 + 1, -350} {
+		data, err := json.Marshal(amount)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Money
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("Unmarshal %s: %v", data, err)
+		}
+		if decoded != amount {
+			t.Errorf("%d is encoded as %s and decoded as %d", int64(amount), data, int64(decoded))
+		}
+	}
+}
+
+func TestMoney_UnmarshalRejectsRounding(t *testing.T) {
+	var payment Payment
+	if err := json.Unmarshal([]byte(`{"amount": 10.005}`), &payment); err == nil {
+		t.Errorf("Amount with three fractional digits is accepted as %v", payment.Amount)
+	}
+}
+
+func TestMoney_FitsCurrency(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     bool
+	}{
+		{181750, "USD", true},
+		{181701, "USD", true},
+		{181700, "JPY", true},
+		{181750, "JPY", false},
+		{181750, "jpy", false},
+		{181701, "KWD", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.amount.FitsCurrency(tt.currency); got != tt.want {
+			t.Errorf("Money(%d).FitsCurrency(%s) = %v, wanted %v", int64(tt.amount), tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestCurrencyExponent(t *testing.T) {
+	tests := map[string]int{"USD": 2, "RUB": 2, "JPY": 0, "KRW": 0, "KWD": 3, "BHD": 3, "XYZ": 2}
+	for currency, want := range tests {
+		if got := CurrencyExponent(currency); got != want {
+			t.Errorf("CurrencyExponent(%s) = %d, wanted %d", currency, got, want)
+		}
+	}
+}
+
+func TestOrder_JSONRoundTrip(t *testing.T) {
+	order := Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Payment: Payment{
+			Amount:       181755,
+			DeliveryCost: 150000,
+			GoodsTotal:   31755,
+			CustomFee:    0,
+		},
+		Items: []Item{{Price: 45364, Sale: 30, TotalPrice: 31755}},
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Order
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, order) {
+		t.Errorf("Order changed in a JSON round trip:\n got %+v\nwant %+v", decoded, order)
+	}
+}
+
+func TestMoney_Scan(t *testing.T) {
+	var amount Money
+	if err := amount.Scan(int64(181750)); err != nil || amount != 181750 {
+		t.Errorf("Scan(int64) = %d, %v", amount, err)
+	}
+	if err := amount.Scan("18.17"); err == nil {
+		t.Error("Scan of a string should fail")
+	}
+	value, err := Money(181750).Value()
+	if err != nil || value != int64(181750) {
+		t.Errorf("Value() = %v, %v", value, err)
+	}
+}
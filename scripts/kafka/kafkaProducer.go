@@ -79,7 +79,7 @@ func ensureTopic() error {
 
 	if _, exists := topics["orders"]; !exists {
 		topicDetail := &sarama.TopicDetail{
-			NumPartitions:     1,
+			NumPartitions:     3,
 			ReplicationFactor: 1,
 		}
 		err = admin.CreateTopic("orders", topicDetail, false)